@@ -0,0 +1,597 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// screen identifies the view the app is showing
+type screen int
+
+const (
+	menuScreen screen = iota
+	listScreen
+	editorScreen
+	newNoteScreen
+	renameScreen
+	themeScreen
+	trashScreen
+	bulkScreen
+	viewerScreen
+	calendarScreen
+	passphraseScreen
+)
+
+// screenDoneMsg is sent by a screen when the user is finished with it. The app
+// then looks at the screen's state (the menu choice, the list action, whether
+// the editor saved) to decide what to show next.
+type screenDoneMsg struct{}
+
+// screenDone is the command screens return instead of tea.Quit
+func screenDone() tea.Msg {
+	return screenDoneMsg{}
+}
+
+// appModel is the root of the interactive UI: a single Bubble Tea program that
+// routes between the menu, the note list, the editor, the theme picker, the
+// trash and dialogs. Screens keep their state while another one is shown, so
+// coming back to the list returns to the same folder, selection and filter.
+type appModel struct {
+	screen   screen
+	root     screen // leaving this screen quits the app
+	notesDir string
+	width    int
+	height   int
+
+	menu     menuModel
+	list     noteListModel
+	hasList  bool
+	viewer   noteViewerModel
+	editor   noteEditorModel
+	editFrom screen // screen the note was opened for editing from
+	newNote  writeInputModel
+	newFrom  screen // screen the new note form was opened from
+	rename   renameModel
+	themes   themeSelectModel
+	trash    trashListModel
+	bulk     bulkModel
+	calendar calendarModel
+	watcher  *notesWatcher
+
+	// Encrypted note waiting for its passphrase, what to do with it once
+	// unlocked ("open" or "view") and the screen it was opened from
+	passphrase passphraseModel
+	unlockName string
+	unlockNext string
+	unlockFrom screen
+
+	// Note open in $VISUAL/$EDITOR and its content before editing
+	externalName   string
+	externalBefore []byte
+
+	saved bool // the editor saved the note (read by "ks -r" once the app exits)
+}
+
+// newAppModel creates the app starting (and ending) on the given screen
+// Callers starting on the list, editor or new note form set that screen up first
+func newAppModel(start screen) appModel {
+	notesDir, _ := getNotesDir()
+	return appModel{
+		screen:   start,
+		root:     start,
+		notesDir: notesDir,
+		menu:     newMenuModel(),
+		newNote:  newWriteInputModel(),
+		newFrom:  start,
+	}
+}
+
+// runApp runs the app until its first screen is closed and returns its final state
+func runApp(app appModel, opts ...tea.ProgramOption) appModel {
+	result, err := tea.NewProgram(app, opts...).Run()
+	app.watcher.Close()
+	if err != nil {
+		fail(exitFailure, "%v", err)
+	}
+	return result.(appModel)
+}
+
+// setList installs the note list and starts watching the notes directory for it
+func (a *appModel) setList(m noteListModel) {
+	if a.watcher == nil {
+		a.watcher = watchNotes(a.notesDir)
+	}
+	m.watcher = a.watcher
+	a.list = m
+	a.hasList = true
+}
+
+func (a appModel) Init() tea.Cmd {
+	switch a.screen {
+	case listScreen:
+		return a.list.Init()
+	case editorScreen:
+		return a.editor.Init()
+	case newNoteScreen:
+		return a.newNote.Init()
+	case passphraseScreen:
+		return a.passphrase.Init()
+	}
+	return nil
+}
+
+func (a appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		a.width = msg.Width
+		a.height = msg.Height
+
+	case screenDoneMsg:
+		return a.closeScreen()
+
+	case editorFinishedMsg:
+		return a.externalEditorDone(msg.err)
+
+	case notesChangedMsg:
+		// The list keeps up with changes while other screens are open
+		if a.hasList {
+			updated, cmd := a.list.Update(msg)
+			a.list = updated.(noteListModel)
+			return a, cmd
+		}
+		return a, nil
+
+	case clearNotificationMsg:
+		if a.screen != trashScreen && a.hasList {
+			updated, cmd := a.list.Update(msg)
+			a.list = updated.(noteListModel)
+			return a, cmd
+		}
+	}
+
+	return a, a.update(msg)
+}
+
+// update passes a message to the screen being shown
+func (a *appModel) update(msg tea.Msg) tea.Cmd {
+	var updated tea.Model
+	var cmd tea.Cmd
+	switch a.screen {
+	case menuScreen:
+		updated, cmd = a.menu.Update(msg)
+		a.menu = updated.(menuModel)
+	case listScreen:
+		updated, cmd = a.list.Update(msg)
+		a.list = updated.(noteListModel)
+	case viewerScreen:
+		updated, cmd = a.viewer.Update(msg)
+		a.viewer = updated.(noteViewerModel)
+	case editorScreen:
+		updated, cmd = a.editor.Update(msg)
+		a.editor = updated.(noteEditorModel)
+	case newNoteScreen:
+		updated, cmd = a.newNote.Update(msg)
+		a.newNote = updated.(writeInputModel)
+	case renameScreen:
+		updated, cmd = a.rename.Update(msg)
+		a.rename = updated.(renameModel)
+	case themeScreen:
+		updated, cmd = a.themes.Update(msg)
+		a.themes = updated.(themeSelectModel)
+	case trashScreen:
+		updated, cmd = a.trash.Update(msg)
+		a.trash = updated.(trashListModel)
+	case bulkScreen:
+		updated, cmd = a.bulk.Update(msg)
+		a.bulk = updated.(bulkModel)
+	case calendarScreen:
+		updated, cmd = a.calendar.Update(msg)
+		a.calendar = updated.(calendarModel)
+	case passphraseScreen:
+		updated, cmd = a.passphrase.Update(msg)
+		a.passphrase = updated.(passphraseModel)
+	}
+	return cmd
+}
+
+// show switches to a screen and gives it the current window size
+func (a *appModel) show(s screen) tea.Cmd {
+	a.screen = s
+	if a.width == 0 || a.height == 0 {
+		return nil
+	}
+	return a.update(tea.WindowSizeMsg{Width: a.width, Height: a.height})
+}
+
+// closeScreen decides where to go when the current screen is finished
+func (a appModel) closeScreen() (tea.Model, tea.Cmd) {
+	switch a.screen {
+	case menuScreen:
+		choice := a.menu.selected
+		a.menu.selected, a.menu.quitting = "", false
+
+		switch choice {
+		case "Notes":
+			return a.showList("")
+		case "New Note":
+			return a.openNewNote()
+		case "Journal":
+			a.calendar = newCalendarModel(a.notesDir)
+			return a, a.show(calendarScreen)
+		case "Trash":
+			purgeTrash(a.notesDir)
+			a.trash = newTrashListModel(a.notesDir)
+			return a, a.show(trashScreen)
+		case "Themes":
+			a.themes = newThemeSelectModel()
+			return a, a.show(themeScreen)
+		}
+		return a, tea.Quit
+
+	case listScreen:
+		return a.listAction()
+
+	case viewerScreen:
+		if a.viewer.edit {
+			a.viewer.edit = false
+			return a.openNote(a.viewer.filename)
+		}
+		if a.root == viewerScreen {
+			return a, tea.Quit
+		}
+		return a.showList("")
+
+	case editorScreen:
+		return a.editorDone()
+
+	case newNoteScreen:
+		if a.root == newNoteScreen {
+			// "ks -w" saves the note itself
+			return a, tea.Quit
+		}
+		if a.newNote.state != 2 {
+			if a.newFrom == menuScreen {
+				return a, a.show(menuScreen)
+			}
+			return a.showList("")
+		}
+		if err := writeNoteQuiet(a.newNote.filename, a.newNote.content); err == errLocked {
+			return a.showList(fmt.Sprintf("'%s' is encrypted - unlock it before replacing it", a.newNote.filename))
+		} else if err != nil && !isCommitError(err) {
+			return a.showList(fmt.Sprintf("Could not create '%s'", a.newNote.filename))
+		}
+		var cmd tea.Cmd
+		a, cmd = a.showList(fmt.Sprintf("Created '%s'", a.newNote.filename))
+		a.list.selectNote(a.newNote.filename)
+		a.list.updatePreview()
+		return a, cmd
+
+	case renameScreen:
+		if a.rename.newName == "" {
+			return a.showList("")
+		}
+		notification := fmt.Sprintf("Renamed to '%s'", a.rename.newName)
+		if a.rename.relinked > 0 {
+			notification += fmt.Sprintf(" (updated links in %s)", pluralNotes(a.rename.relinked))
+		}
+		if a.rename.linkProblem != "" {
+			notification += " (" + a.rename.linkProblem + ")"
+		}
+		var cmd tea.Cmd
+		a, cmd = a.showList(notification)
+		a.list.selectNote(a.rename.newName)
+		a.list.updatePreview()
+		return a, cmd
+
+	case themeScreen:
+		if name := a.themes.selected; name != "" && applyTheme(name) {
+			// Apply the selected theme and save it to the config file
+			config.Theme = name
+			if err := saveConfigValue("theme", quoteConfigString(name)); err != nil {
+				a.menu.status = "Could not save the theme: " + err.Error()
+			}
+			if a.hasList {
+				a.list.restyle()
+			}
+		}
+		return a, a.show(menuScreen)
+
+	case trashScreen:
+		return a, a.show(menuScreen)
+
+	case bulkScreen:
+		if a.bulk.result == "" {
+			return a.showList("")
+		}
+		a.list.clearMarks()
+		return a.showList(a.bulk.result)
+
+	case calendarScreen:
+		if !a.calendar.open {
+			return a, a.show(menuScreen)
+		}
+		a.calendar.open, a.calendar.quitting = false, false
+		name, created, err := openJournalNote(a.notesDir, a.calendar.cursor)
+		if err != nil {
+			a.calendar.err = err.Error()
+			return a, a.show(calendarScreen)
+		}
+		if created {
+			gitCommit(a.notesDir, "Create "+name)
+		}
+		return a.openNote(name)
+
+	case passphraseScreen:
+		if a.root == passphraseScreen {
+			// Asked for on the command line
+			return a, tea.Quit
+		}
+		name, next := a.unlockName, a.unlockNext
+		a.unlockName, a.unlockNext = "", ""
+		if a.passphrase.passphrase == "" {
+			if a.unlockFrom == viewerScreen || a.unlockFrom == calendarScreen {
+				return a, a.show(a.unlockFrom)
+			}
+			return a.showList("")
+		}
+
+		unlockedNotes[name] = a.passphrase.passphrase
+		a.screen = a.unlockFrom // where the note counts as opened from
+		if next == "view" {
+			return a.openViewer(name)
+		}
+		return a.openNote(name)
+	}
+
+	return a, tea.Quit
+}
+
+// showList goes back to the note list, refreshed, with an optional notification
+func (a appModel) showList(notification string) (appModel, tea.Cmd) {
+	var cmds []tea.Cmd
+	if !a.hasList {
+		notes, _ := loadNotes(a.notesDir, "")
+		a.setList(newNoteListModel(sortNotes(notes, config.Sort), config.Sort))
+		a.list.setDir("")
+		cmds = append(cmds, a.list.Init())
+	} else {
+		a.list.refresh()
+	}
+
+	if notification != "" {
+		a.list.notification = notification
+		a.list.notificationTime = time.Now()
+		cmds = append(cmds, clearNotificationAfter(3*time.Second))
+	}
+	cmds = append(cmds, a.show(listScreen))
+	return a, tea.Batch(cmds...)
+}
+
+// listAction carries out what the user picked in the note list
+func (a appModel) listAction() (tea.Model, tea.Cmd) {
+	action, selected := a.list.action, a.list.selected
+	a.list.action, a.list.quitting = "", false
+	a.list.confirmingDelete, a.list.deleteCursor = false, 0
+
+	switch action {
+	case "open":
+		if selected != nil {
+			return a.openNote(selected.name)
+		}
+	case "view":
+		if selected != nil {
+			return a.openViewer(selected.name)
+		}
+	case "create":
+		return a.openNewNote()
+	case "rename":
+		if selected != nil {
+			a.rename = newRenameModel(a.notesDir, selected.name)
+			return a, tea.Batch(a.show(renameScreen), textinput.Blink)
+		}
+	case "bulk":
+		a.bulk = newBulkModel(a.notesDir, a.list.bulkKind, a.list.markedNotes())
+		return a, tea.Batch(a.show(bulkScreen), a.bulk.Init())
+	case "delete":
+		if selected != nil {
+			if err := deleteNoteQuiet(selected.name); err != nil {
+				return a.showList(fmt.Sprintf("Could not delete '%s'", selected.name))
+			}
+			return a.showList(fmt.Sprintf("Moved '%s' to trash", selected.name))
+		}
+	default:
+		// Back to the menu, or out of the app when it was started on the list
+		if a.root == listScreen {
+			return a, tea.Quit
+		}
+		return a, a.show(menuScreen)
+	}
+	return a, a.show(listScreen)
+}
+
+// openNewNote shows the form for creating a note
+func (a appModel) openNewNote() (tea.Model, tea.Cmd) {
+	a.newFrom = a.screen
+	a.newNote = newWriteInputModel()
+	return a, tea.Batch(a.show(newNoteScreen), a.newNote.Init())
+}
+
+// unlock asks for the passphrase of an encrypted note, then opens it ("open") or views it ("view")
+func (a appModel) unlock(name, next string) (tea.Model, tea.Cmd) {
+	content, err := os.ReadFile(notePath(a.notesDir, name))
+	if err != nil {
+		return a.showList(fmt.Sprintf("Could not open '%s'", name))
+	}
+	a.unlockName, a.unlockNext, a.unlockFrom = name, next, a.screen
+	a.passphrase = newPassphraseModel("Unlock "+name, content)
+	return a, tea.Batch(a.show(passphraseScreen), a.passphrase.Init())
+}
+
+// openViewer shows a note in the read-only viewer
+func (a appModel) openViewer(name string) (tea.Model, tea.Cmd) {
+	content, _, err := readNoteText(a.notesDir, name)
+	if err == errLocked {
+		return a.unlock(name, "view")
+	}
+	if err != nil {
+		return a.showList(fmt.Sprintf("Could not open '%s'", name))
+	}
+	a.viewer = newNoteViewerModel(a.notesDir, name, content)
+	return a, a.show(viewerScreen)
+}
+
+// openNote opens a note in $VISUAL/$EDITOR if configured, or the built-in editor
+func (a appModel) openNote(name string) (tea.Model, tea.Cmd) {
+	filePath := notePath(a.notesDir, name)
+	if _, unlocked := unlockedNotes[name]; !unlocked && isEncryptedNote(a.notesDir, name) {
+		return a.unlock(name, "open")
+	}
+	a.editFrom = a.screen
+
+	// Hand the file to $VISUAL/$EDITOR (falls back to the built-in editor)
+	// Encrypted notes stay in the built-in editor so no plaintext is written to disk
+	if config.ExternalEditor && !isEncryptedNote(a.notesDir, name) {
+		content, err := os.ReadFile(filePath)
+		cmd, cmdErr := editorCommand(filePath)
+		if err == nil && cmdErr == nil {
+			snapshotNote(a.notesDir, name)
+			a.externalName, a.externalBefore = name, content
+			return a, tea.ExecProcess(cmd, func(err error) tea.Msg {
+				return editorFinishedMsg{err: err}
+			})
+		}
+	}
+
+	return a.openEditor(name)
+}
+
+// openEditor opens a note in the built-in editor
+func (a appModel) openEditor(name string) (tea.Model, tea.Cmd) {
+	filePath := notePath(a.notesDir, name)
+	content, version, err := readFileVersion(filePath)
+	if err != nil {
+		return a.leaveEditor(fmt.Sprintf("Could not open '%s'", name))
+	}
+	text, passphrase, err := openNoteText(name, content)
+	if err == errLocked {
+		return a.unlock(name, "open")
+	}
+	if err != nil {
+		return a.leaveEditor(fmt.Sprintf("Could not open '%s': %v", name, err))
+	}
+
+	a.editor = newNoteEditorModel(name, text)
+	a.editor.passphrase = passphrase
+	a.editor.watchFile(filePath, version)
+	return a, tea.Batch(a.show(editorScreen), a.editor.Init())
+}
+
+// externalEditorDone records the changes made in $VISUAL/$EDITOR
+func (a appModel) externalEditorDone(err error) (tea.Model, tea.Cmd) {
+	name, before := a.externalName, a.externalBefore
+	a.externalName, a.externalBefore = "", nil
+
+	if err != nil {
+		// Fall back to the built-in editor
+		return a.openEditor(name)
+	}
+
+	after, err := os.ReadFile(notePath(a.notesDir, name))
+	if err != nil || bytes.Equal(after, before) {
+		return a.leaveEditor("")
+	}
+	// Recorded like any other save; $EDITOR already wrote the file
+	if err := saveNote(a.notesDir, name, after, "Edit "+name); err != nil && !isCommitError(err) {
+		return a.leaveEditor(fmt.Sprintf("Could not save '%s': %v", name, err))
+	}
+	a.saved = true
+	return a.leaveEditor(fmt.Sprintf("Saved changes to '%s'", name))
+}
+
+// editorDone saves the note if the user pressed Ctrl+S, and opens the note
+// a followed [[link]] points to
+func (a appModel) editorDone() (tea.Model, tea.Cmd) {
+	name, follow := a.editor.filename, a.editor.follow
+	if !a.editor.saved {
+		if follow != "" {
+			return a.openEditor(follow)
+		}
+		return a.leaveEditor("")
+	}
+
+	data := []byte(a.editor.content)
+	if a.editor.passphrase != "" {
+		sealed, err := encryptNote(data, a.editor.passphrase)
+		if err != nil {
+			a.editor.saved, a.editor.quitting = false, false
+			a.editor.status = "Could not encrypt: " + err.Error()
+			return a, nil
+		}
+		data = sealed
+	}
+	if err := saveNote(a.notesDir, name, data, "Edit "+name); err != nil && !isCommitError(err) {
+		// Stay in the editor with the unsaved changes so nothing is lost
+		a.editor.saved, a.editor.quitting = false, false
+		a.editor.status = "Could not save: " + err.Error()
+		return a, nil
+	}
+
+	a.saved = true
+	if follow != "" {
+		return a.openEditor(follow)
+	}
+	return a.leaveEditor(fmt.Sprintf("Saved changes to '%s'", name))
+}
+
+// leaveEditor goes back to where the note was opened from: the viewer, showing
+// the saved changes, the journal calendar or the note list
+func (a appModel) leaveEditor(notification string) (tea.Model, tea.Cmd) {
+	switch {
+	case a.root == editorScreen:
+		// "ks today" edits the note on its own
+		return a, tea.Quit
+	case a.editFrom == viewerScreen:
+		a.viewer.reload()
+		if notification != "" {
+			a.viewer.setStatus(notification, false)
+		}
+		return a, a.show(viewerScreen)
+	case a.editFrom == calendarScreen:
+		a.calendar.refresh()
+		return a, a.show(calendarScreen)
+	}
+	return a.showList(notification)
+}
+
+func (a appModel) View() string {
+	switch a.screen {
+	case menuScreen:
+		return a.menu.View()
+	case listScreen:
+		return a.list.View()
+	case viewerScreen:
+		return a.viewer.View()
+	case editorScreen:
+		return a.editor.View()
+	case newNoteScreen:
+		return a.newNote.View()
+	case renameScreen:
+		return a.rename.View()
+	case themeScreen:
+		return a.themes.View()
+	case trashScreen:
+		return a.trash.View()
+	case bulkScreen:
+		return a.bulk.View()
+	case calendarScreen:
+		return a.calendar.View()
+	case passphraseScreen:
+		return a.passphrase.View()
+	}
+	return ""
+}
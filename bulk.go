@@ -0,0 +1,578 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// markDelegate draws list items like the default delegate, with a dot after marked notes
+type markDelegate struct {
+	list.DefaultDelegate
+	marked map[string]bool
+}
+
+// markedItem shows a marked note with the mark after its name
+type markedItem struct {
+	noteInfo
+}
+
+func (n markedItem) Title() string {
+	return n.noteInfo.Title() + " ●"
+}
+
+func (d markDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	if note, ok := item.(noteInfo); ok && d.marked[note.name] {
+		item = markedItem{note}
+	}
+	d.DefaultDelegate.Render(w, m, index, item)
+}
+
+// bulkHelpKeys lists the marking and bulk action keys in the list's full help
+func bulkHelpKeys() []key.Binding {
+	return []key.Binding{
+		key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "mark")),
+		key.NewBinding(key.WithKeys("V"), key.WithHelp("V", "mark range")),
+		key.NewBinding(key.WithKeys("*"), key.WithHelp("*", "mark all")),
+		key.NewBinding(key.WithKeys("m"), key.WithHelp("m", "move")),
+		key.NewBinding(key.WithKeys("T"), key.WithHelp("T", "tag")),
+		key.NewBinding(key.WithKeys("x"), key.WithHelp("x", "export")),
+		key.NewBinding(key.WithKeys("M"), key.WithHelp("M", "merge")),
+	}
+}
+
+// bulkModel is the confirmation dialog for an action on several notes
+// ("delete", "move", "tag", "export" or "merge"). It shows what will happen
+// as the folder, tags or file name are typed, and carries the action out on Enter.
+type bulkModel struct {
+	kind     string
+	notes    []noteInfo
+	notesDir string
+	input    textinput.Model
+	hasInput bool
+	problem  string   // why the action can't be carried out as typed ("" = it can)
+	plan     []string // summary lines
+	err      string   // error from carrying the action out
+	result   string   // notification for the list once done ("" = cancelled)
+	width    int
+	height   int
+}
+
+func newBulkModel(notesDir, kind string, notes []noteInfo) bulkModel {
+	ti := textinput.New()
+	ti.CharLimit = 255
+	ti.Width = 50
+
+	m := bulkModel{kind: kind, notes: notes, notesDir: notesDir, hasInput: kind != "delete"}
+	switch kind {
+	case "move":
+		ti.Placeholder = "folder (empty = top level)"
+	case "tag":
+		ti.Placeholder = "meeting -draft"
+	case "export":
+		ti.SetValue("ks-export-" + time.Now().Format("2006-01-02") + ".zip")
+	case "merge":
+		ti.SetValue(notes[0].name)
+	}
+	ti.CursorEnd()
+	ti.Focus()
+	m.input = ti
+
+	m.check()
+	return m
+}
+
+// prompt is the question above the input
+func (m bulkModel) prompt() string {
+	count := pluralNotes(len(m.notes))
+	switch m.kind {
+	case "move":
+		return "Move " + count + " to folder:"
+	case "tag":
+		return "Tag " + count + " (prefix a tag with - to remove it):"
+	case "export":
+		return "Export " + count + " to:"
+	case "merge":
+		return "Merge " + count + " into:"
+	}
+	return "Delete " + count + "?"
+}
+
+// pluralNotes formats a note count, e.g. "1 note" or "3 notes"
+func pluralNotes(n int) string {
+	if n == 1 {
+		return "1 note"
+	}
+	return fmt.Sprintf("%d notes", n)
+}
+
+// check works out what the action will do with the current input
+func (m *bulkModel) check() {
+	m.problem, m.plan = "", nil
+	value := strings.TrimSpace(m.input.Value())
+
+	switch m.kind {
+	case "delete":
+		for _, note := range m.notes {
+			m.plan = append(m.plan, note.name)
+		}
+		m.plan = append(m.plan, "", "They can be restored from the trash")
+
+	case "move":
+		folder := strings.Trim(value, "/")
+		if folder != "" {
+			if err := validateFilename(folder); err != nil {
+				m.problem = err.Error()
+				return
+			}
+		}
+		moving := 0
+		for _, move := range m.moves(folder) {
+			switch {
+			case move.skip != "":
+				m.plan = append(m.plan, move.from+" ("+move.skip+")")
+			default:
+				m.plan = append(m.plan, move.from+" → "+move.to)
+				moving++
+			}
+		}
+		if moving == 0 {
+			m.problem = "Nothing to move"
+		}
+
+	case "tag":
+		add, remove := parseTagChanges(value)
+		if len(add) == 0 && len(remove) == 0 {
+			m.problem = "Type the tags to add or remove"
+			return
+		}
+		changing := 0
+		for _, note := range m.notes {
+			tags := changeTags(note.meta.tags, add, remove)
+			if formatTags(tags) == formatTags(note.meta.tags) {
+				m.plan = append(m.plan, note.name+" (unchanged)")
+				continue
+			}
+			m.plan = append(m.plan, note.name+": "+tagList(note.meta.tags)+" → "+tagList(tags))
+			changing++
+		}
+		if changing == 0 {
+			m.problem = "No tags would change"
+		}
+
+	case "export":
+		format := formatFromOutput(value)
+		if format == "" {
+			m.problem = "Use a .zip, .tar.gz, .md or .jsonl file, or a folder name for HTML"
+			return
+		}
+		for _, note := range m.notes {
+			m.plan = append(m.plan, note.name)
+		}
+		m.plan = append(m.plan, "", "Format: "+format)
+		if _, err := os.Stat(value); err == nil {
+			m.plan = append(m.plan, "'"+value+"' already exists and will be overwritten")
+		}
+
+	case "merge":
+		if len(m.notes) < 2 {
+			m.problem = "Mark at least 2 notes to merge"
+			return
+		}
+		if value == "" {
+			m.problem = "Filename cannot be empty"
+			return
+		}
+		if err := validateFilename(value); err != nil {
+			m.problem = err.Error()
+			return
+		}
+		if info, err := os.Stat(notePath(m.notesDir, value)); err == nil {
+			if info.IsDir() {
+				m.problem = fmt.Sprintf("'%s' is a folder", value)
+				return
+			}
+			if !m.includes(value) {
+				m.problem = fmt.Sprintf("'%s' already exists and isn't one of the marked notes", value)
+				return
+			}
+		}
+		for _, note := range m.notes {
+			m.plan = append(m.plan, note.name)
+		}
+		trashed := len(m.notes)
+		if m.includes(value) {
+			trashed--
+		}
+		m.plan = append(m.plan, "", fmt.Sprintf("The other %s move to the trash", pluralNotes(trashed)))
+	}
+}
+
+// includes reports whether a note is one of the notes being acted on
+func (m bulkModel) includes(name string) bool {
+	for _, note := range m.notes {
+		if note.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// bulkMove is one note to move into a folder, or why it stays where it is
+type bulkMove struct {
+	from, to string
+	skip     string
+}
+
+// moves plans moving each note into folder, keeping its file name
+func (m bulkModel) moves(folder string) []bulkMove {
+	var moves []bulkMove
+	taken := make(map[string]bool)
+	for _, note := range m.notes {
+		move := bulkMove{from: note.name, to: path.Join(folder, path.Base(note.name))}
+		if move.to == move.from {
+			move.skip = "already there"
+		} else if _, err := os.Stat(notePath(m.notesDir, move.to)); err == nil || taken[move.to] {
+			move.skip = "'" + move.to + "' exists"
+		} else {
+			taken[move.to] = true
+		}
+		moves = append(moves, move)
+	}
+	return moves
+}
+
+// parseTagChanges splits "meeting -draft" into tags to add and tags to remove
+func parseTagChanges(value string) (add, remove []string) {
+	for _, field := range strings.Fields(value) {
+		if strings.HasPrefix(field, "-") {
+			remove = appendTags(remove, field[1:])
+		} else {
+			add = appendTags(add, field)
+		}
+	}
+	return add, remove
+}
+
+// changeTags returns tags with the given tags removed and added
+func changeTags(tags, add, remove []string) []string {
+	var changed []string
+	for _, tag := range tags {
+		removed := false
+		for _, r := range remove {
+			if tag == r {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			changed = append(changed, tag)
+		}
+	}
+	return appendTags(changed, strings.Join(add, " "))
+}
+
+// tagList formats tags for the summary, showing "no tags" for none
+func tagList(tags []string) string {
+	if len(tags) == 0 {
+		return "no tags"
+	}
+	return formatTags(tags)
+}
+
+// apply carries the action out
+// It stops at the first error; if some notes were already done the dialog closes
+// and the notification says how far it got.
+func (m bulkModel) apply() (tea.Model, tea.Cmd) {
+	value := strings.TrimSpace(m.input.Value())
+
+	var done int
+	var err error
+	switch m.kind {
+	case "delete":
+		done, err = m.deleteNotes()
+		m.result = "Moved " + pluralNotes(done) + " to trash"
+	case "move":
+		folder := strings.Trim(value, "/")
+		done, err = m.moveNotes(folder)
+		if folder == "" {
+			folder = "the top level"
+		} else {
+			folder = "'" + folder + "'"
+		}
+		m.result = "Moved " + pluralNotes(done) + " to " + folder
+	case "tag":
+		done, err = m.tagNotes(value)
+		m.result = "Tagged " + pluralNotes(done)
+	case "export":
+		err = exportNotes(m.notesDir, m.notes, formatFromOutput(value), value, true)
+		m.result = fmt.Sprintf("Exported %s to '%s'", pluralNotes(len(m.notes)), value)
+	case "merge":
+		done, err = m.mergeNotes(value)
+		m.result = fmt.Sprintf("Merged %s into '%s'", pluralNotes(len(m.notes)), value)
+	}
+
+	if err != nil {
+		if done == 0 {
+			m.result = ""
+			m.err = err.Error()
+			return m, nil
+		}
+		var linkErr *linkError
+		if errors.As(err, &linkErr) {
+			m.result += " (" + err.Error() + ")"
+		} else {
+			m.result += " (stopped: " + err.Error() + ")"
+		}
+	}
+	return m, screenDone
+}
+
+// deleteNotes moves the notes to the trash
+func (m bulkModel) deleteNotes() (int, error) {
+	var names []string
+	var err error
+	for _, note := range m.notes {
+		if err = moveToTrash(m.notesDir, note.name); err != nil {
+			break
+		}
+		names = append(names, note.name)
+	}
+	if len(names) > 0 {
+		updateIndex(m.notesDir, names...)
+		gitCommit(m.notesDir, "Delete "+pluralNotes(len(names)))
+	}
+	return len(names), err
+}
+
+// moveNotes moves the notes into a folder, skipping any whose name is taken there
+func (m bulkModel) moveNotes(folder string) (int, error) {
+	var names, unlinked []string
+	var err error
+	for _, move := range m.moves(folder) {
+		if move.skip != "" {
+			continue
+		}
+		linking := linksTo(m.notesDir, move.from)
+		if err = moveNote(m.notesDir, move.from, move.to, false); err != nil {
+			break
+		}
+		updateIndex(m.notesDir, move.from, move.to)
+		names = append(names, move.from, move.to)
+		var linkErr *linkError
+		if _, err := updateLinks(m.notesDir, move.from, move.to, linking); errors.As(err, &linkErr) {
+			unlinked = append(unlinked, linkErr.names...)
+		}
+	}
+	if err == nil && len(unlinked) > 0 {
+		err = &linkError{unlinked}
+	}
+	if len(names) > 0 {
+		if folder == "" {
+			folder = "top level"
+		}
+		gitCommit(m.notesDir, fmt.Sprintf("Move %s to %s", pluralNotes(len(names)/2), folder))
+	}
+	return len(names) / 2, err
+}
+
+// tagNotes adds and removes tags in each note's front matter
+func (m bulkModel) tagNotes(value string) (int, error) {
+	add, remove := parseTagChanges(value)
+
+	var names []string
+	var err error
+	for _, note := range m.notes {
+		filePath := notePath(m.notesDir, note.name)
+		var content []byte
+		if content, err = os.ReadFile(filePath); err != nil {
+			break
+		}
+		if isEncrypted(content) {
+			err = fmt.Errorf("'%s' is encrypted", note.name)
+			break
+		}
+		meta := parseFrontMatter(frontMatterLines(string(content)))
+		tags := changeTags(meta.tags, add, remove)
+		if formatTags(tags) == formatTags(meta.tags) {
+			continue
+		}
+
+		if err = saveNote(m.notesDir, note.name, []byte(setTags(string(content), tags)), ""); err != nil {
+			break
+		}
+		names = append(names, note.name)
+	}
+	if len(names) > 0 {
+		gitCommit(m.notesDir, "Tag "+pluralNotes(len(names)))
+	}
+	return len(names), err
+}
+
+// mergeNotes combines the notes into one, in list order with a heading per note
+// The front matter of each note is dropped and their tags are combined.
+// The target may be one of the notes; the others are moved to the trash.
+// Returns how many notes were handled: once the merged note is written, the
+// target and each note moved to the trash.
+func (m bulkModel) mergeNotes(target string) (int, error) {
+	var tags []string
+	var body strings.Builder
+	for _, note := range m.notes {
+		content, err := os.ReadFile(notePath(m.notesDir, note.name))
+		if err != nil {
+			return 0, err
+		}
+		if isEncrypted(content) {
+			return 0, fmt.Errorf("'%s' is encrypted - decrypt it first", note.name)
+		}
+		meta := parseFrontMatter(frontMatterLines(string(content)))
+		tags = appendTags(tags, strings.Join(meta.tags, " "))
+
+		heading := meta.title
+		if heading == "" {
+			heading = note.name
+		}
+		text := strings.TrimSpace(stripFrontMatter(string(content)))
+		fmt.Fprintf(&body, "## %s\n\n%s\n\n", heading, text)
+	}
+
+	merged := strings.TrimRight(body.String(), "\n") + "\n"
+	if len(tags) > 0 {
+		merged = buildFrontMatter("", tags, time.Time{}) + merged
+	}
+
+	// A target that isn't one of the notes must not exist yet
+	if !m.includes(target) {
+		if _, err := os.Lstat(notePath(m.notesDir, target)); err == nil {
+			return 0, &fs.PathError{Op: "create", Path: target, Err: fs.ErrExist}
+		}
+	}
+	err := saveNote(m.notesDir, target, []byte(merged), "")
+	if err != nil {
+		return 0, err
+	}
+
+	names := []string{target}
+	for _, note := range m.notes {
+		if note.name == target {
+			continue
+		}
+		if err = moveToTrash(m.notesDir, note.name); err != nil {
+			break
+		}
+		names = append(names, note.name)
+	}
+	updateIndex(m.notesDir, names...)
+	gitCommit(m.notesDir, fmt.Sprintf("Merge %s into %s", pluralNotes(len(m.notes)), target))
+	return len(names), err
+}
+
+func (m bulkModel) Init() tea.Cmd {
+	if m.hasInput {
+		return textinput.Blink
+	}
+	return nil
+}
+
+func (m bulkModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "esc", "ctrl+c":
+			return m, screenDone
+		case "enter":
+			if m.problem != "" {
+				return m, nil
+			}
+			return m.apply()
+		case "y", "Y":
+			if !m.hasInput {
+				return m.apply()
+			}
+		case "n", "N":
+			if !m.hasInput {
+				return m, screenDone
+			}
+		}
+	}
+
+	if !m.hasInput {
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	if _, ok := msg.(tea.KeyMsg); ok {
+		m.err = ""
+		m.check()
+	}
+	return m, cmd
+}
+
+// maxPlanLines is how many summary lines the dialog shows before cutting the list short
+const maxPlanLines = 10
+
+func (m bulkModel) View() string {
+	var content strings.Builder
+	title := theme.Primary
+	if m.kind == "delete" || m.kind == "merge" {
+		title = theme.Warning
+	}
+	content.WriteString(title.Render(m.prompt()) + "\n\n")
+	if m.hasInput {
+		content.WriteString(m.input.View() + "\n\n")
+	}
+
+	switch {
+	case m.err != "":
+		content.WriteString(theme.Error.Render("✗ " + m.err))
+	case m.problem != "":
+		content.WriteString(theme.Error.Render("✗ " + m.problem))
+	default:
+		plan := m.plan
+		if len(plan) > maxPlanLines {
+			more := len(plan) - maxPlanLines + 1
+			plan = append(plan[:maxPlanLines-1:maxPlanLines-1], fmt.Sprintf("...and %d more", more))
+		}
+		for i, line := range plan {
+			if i > 0 {
+				content.WriteString("\n")
+			}
+			content.WriteString(theme.Muted.Render(line))
+		}
+	}
+
+	footer := "Enter: confirm • Esc: cancel"
+	if !m.hasInput {
+		footer = "y/Enter: confirm • n/Esc: cancel"
+	}
+	content.WriteString("\n\n" + theme.Muted.Render(footer))
+
+	borderColor := theme.Accent.GetForeground()
+	if m.kind == "delete" || m.kind == "merge" {
+		borderColor = theme.Warning.GetForeground()
+	}
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(borderColor).
+		Padding(1, 2).
+		Width(70)
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, boxStyle.Render(content.String()), lipgloss.WithWhitespaceChars(" "))
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// calendarModel is the journal's month view, opened from the menu
+// Days with a journal note are highlighted; Enter opens (or creates) the selected day's note.
+type calendarModel struct {
+	notesDir string
+	cursor   time.Time       // selected day
+	today    time.Time       // midnight today
+	entries  map[string]bool // days with a journal note, by dayKey
+	open     bool            // the user pressed Enter on the cursor's day
+	err      string          // shown under the calendar, e.g. a missing template
+	quitting bool
+	width    int
+	height   int
+}
+
+func newCalendarModel(notesDir string) calendarModel {
+	today, _ := parseJournalDate("today", time.Now())
+	return calendarModel{
+		notesDir: notesDir,
+		cursor:   today,
+		today:    today,
+		entries:  journalEntries(notesDir),
+	}
+}
+
+// refresh reloads the days that have entries, e.g. after a note was created
+func (m *calendarModel) refresh() {
+	m.entries = journalEntries(m.notesDir)
+}
+
+// moveMonths moves the cursor by whole months, staying within the target month
+func (m *calendarModel) moveMonths(months int) {
+	first := time.Date(m.cursor.Year(), m.cursor.Month()+time.Month(months), 1, 0, 0, 0, 0, time.Local)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	m.cursor = first.AddDate(0, 0, min(m.cursor.Day(), lastDay)-1)
+}
+
+// jumpToEntry moves the cursor to the next (dir 1) or previous (dir -1) day with an entry
+func (m *calendarModel) jumpToEntry(dir int) bool {
+	keys := make([]string, 0, len(m.entries))
+	for key := range m.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	current := dayKey(m.cursor)
+	target := ""
+	if dir > 0 {
+		if i := sort.SearchStrings(keys, current+"~"); i < len(keys) {
+			target = keys[i]
+		}
+	} else if i := sort.SearchStrings(keys, current); i > 0 {
+		target = keys[i-1]
+	}
+	if target == "" {
+		return false
+	}
+	day, err := time.ParseInLocation("2006-01-02", target, time.Local)
+	if err != nil {
+		return false
+	}
+	m.cursor = day
+	return true
+}
+
+func (m calendarModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m calendarModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+
+	case tea.KeyMsg:
+		m.err = ""
+		switch msg.String() {
+		case "ctrl+c", "q", "esc":
+			m.quitting = true
+			return m, screenDone
+
+		case "left", "h":
+			m.cursor = m.cursor.AddDate(0, 0, -1)
+		case "right", "l":
+			m.cursor = m.cursor.AddDate(0, 0, 1)
+		case "up", "k":
+			m.cursor = m.cursor.AddDate(0, 0, -7)
+		case "down", "j":
+			m.cursor = m.cursor.AddDate(0, 0, 7)
+		case "[", "pgup":
+			m.moveMonths(-1)
+		case "]", "pgdown":
+			m.moveMonths(1)
+		case "{":
+			m.moveMonths(-12)
+		case "}":
+			m.moveMonths(12)
+		case "t":
+			m.cursor = m.today
+
+		case "n":
+			if !m.jumpToEntry(1) {
+				m.err = "No later entries"
+			}
+		case "p", "N":
+			if !m.jumpToEntry(-1) {
+				m.err = "No earlier entries"
+			}
+
+		case "enter":
+			m.open = true
+			m.quitting = true
+			return m, screenDone
+		}
+	}
+	return m, nil
+}
+
+func (m calendarModel) View() string {
+	if m.quitting {
+		return ""
+	}
+
+	first := time.Date(m.cursor.Year(), m.cursor.Month(), 1, 0, 0, 0, 0, time.Local)
+	daysInMonth := first.AddDate(0, 1, -1).Day()
+
+	entries := 0
+	for day := 1; day <= daysInMonth; day++ {
+		if m.entries[dayKey(first.AddDate(0, 0, day-1))] {
+			entries++
+		}
+	}
+	month := theme.Primary.Render(first.Format("January 2006"))
+	if entries == 1 {
+		month += theme.Muted.Render(" • 1 entry")
+	} else if entries > 1 {
+		month += theme.Muted.Render(fmt.Sprintf(" • %d entries", entries))
+	}
+
+	// Weeks start on Monday
+	var grid strings.Builder
+	grid.WriteString(theme.Muted.Render("Mo Tu We Th Fr Sa Su"))
+	grid.WriteString("\n")
+	column := (int(first.Weekday()) + 6) % 7
+	grid.WriteString(strings.Repeat("   ", column))
+	for day := 1; day <= daysInMonth; day++ {
+		date := first.AddDate(0, 0, day-1)
+		style := theme.Secondary
+		if m.entries[dayKey(date)] {
+			style = theme.Accent
+		}
+		if date.Equal(m.today) {
+			style = style.Underline(true)
+		}
+		if date.Equal(m.cursor) {
+			style = theme.Highlight
+		}
+		grid.WriteString(style.Render(fmt.Sprintf("%2d", day)))
+
+		column++
+		if column == 7 && day < daysInMonth {
+			grid.WriteString("\n")
+			column = 0
+		} else if day < daysInMonth {
+			grid.WriteString(" ")
+		}
+	}
+	// Pad the last week so the centred grid lines up
+	if column < 7 {
+		grid.WriteString(strings.Repeat("   ", 7-column))
+	}
+
+	// The selected day and its note
+	name := journalNoteName(m.cursor)
+	selected := theme.Primary.Render(m.cursor.Format("Monday, January 2, 2006"))
+	if m.entries[dayKey(m.cursor)] {
+		selected += "\n" + theme.Success.Render(name)
+	} else {
+		selected += "\n" + theme.Muted.Render("No entry • Enter creates "+name)
+	}
+	if m.err != "" {
+		selected += "\n" + theme.Warning.Render(m.err)
+	} else {
+		selected += "\n"
+	}
+
+	header := theme.Header.Render(" Journal ")
+	footer := theme.Muted.Render("←/→/↑/↓: move • [/]: month • n/p: next/prev entry • t: today • enter: open • q: back")
+	content := header + "\n\n" + month + "\n\n" + grid.String() + "\n\n" + selected + "\n\n" + footer
+
+	// Calculate vertical centering
+	contentHeight := strings.Count(content, "\n") + 1
+	topPadding := 0
+	if m.height > contentHeight {
+		topPadding = (m.height - contentHeight) / 2
+	}
+	if topPadding > 0 {
+		content = strings.Repeat("\n", topPadding) + content
+	}
+
+	// Center horizontally with full width
+	style := lipgloss.NewStyle().
+		Width(m.width).
+		Align(lipgloss.Center)
+
+	return style.Render(content)
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Exit codes of the ks command, listed in "ks --help"
+const (
+	exitFailure   = 1 // any other error; also "no matches" for search
+	exitUsage     = 2 // unknown command, wrong arguments or flags
+	exitNotFound  = 3 // the note (or version, trash entry) doesn't exist
+	exitInvalid   = 4 // invalid note name
+	exitCancelled = 5 // the user cancelled (answered no, pressed Esc)
+	exitIO        = 6 // reading or writing files failed
+	exitExists    = 7 // the target note already exists
+)
+
+// printError prints an error to stderr, for commands that carry on with the next note
+func printError(format string, args ...any) {
+	fmt.Fprintln(os.Stderr, theme.Error.Render("✗ "+fmt.Sprintf(format, args...)))
+}
+
+// fail prints an error to stderr and exits with the given code
+func fail(code int, format string, args ...any) {
+	printError(format, args...)
+	os.Exit(code)
+}
+
+// failErr fails because of a file operation's error, choosing the exit code
+// from it: not found, already exists or an I/O error
+func failErr(err error, format string, args ...any) {
+	code := exitIO
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		code = exitNotFound
+	case errors.Is(err, fs.ErrExist):
+		code = exitExists
+	}
+	fail(code, "%s: %v", fmt.Sprintf(format, args...), err)
+}
+
+// usageError prints a command's usage to stderr and exits with exitUsage
+func usageError(usage func(io.Writer)) {
+	usage(os.Stderr)
+	os.Exit(exitUsage)
+}
+
+// checkName exits if a note name is invalid, suggesting a fix when there is one
+func checkName(filename string) {
+	err := validateFilename(filename)
+	if err == nil {
+		return
+	}
+	if suggested := suggestFilename(filename); suggested != "" {
+		fail(exitInvalid, "Invalid note name '%s': %v (try '%s')", filename, err, suggested)
+	}
+	fail(exitInvalid, "Invalid note name '%s': %v", filename, err)
+}
+
+// cliNotesDir returns the notes directory, exiting if it can't be found
+func cliNotesDir() string {
+	notesDir, err := getNotesDir()
+	if err != nil {
+		fail(exitIO, "Could not find the notes directory: %v", err)
+	}
+	return notesDir
+}
+
+// existingNote exits unless the named note exists
+func existingNote(notesDir, filename string) {
+	checkName(filename)
+	if _, err := os.Stat(notePath(notesDir, filename)); err != nil {
+		if os.IsNotExist(err) {
+			fail(exitNotFound, "Note '%s' not found", filename)
+		}
+		failErr(err, "Could not read '%s'", filename)
+	}
+}
+
+// parseArgs parses a command's flags wherever they are among its arguments
+// (ks rm a.md --force), returning the other arguments; -- ends the flags
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var rest []string
+	for {
+		fs.Parse(args)
+		consumed := len(args) - fs.NArg()
+		if fs.NArg() == 0 || (consumed > 0 && args[consumed-1] == "--") {
+			return append(rest, fs.Args()...)
+		}
+		rest = append(rest, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// command is a ks subcommand
+type command struct {
+	name    string
+	aliases []string // other names, e.g. "delete" for rm
+	args    string   // argument synopsis shown in the help
+	summary string
+	run     func(args []string)
+	usage   func(io.Writer) // full help for "ks <command> --help" (nil = synopsis and summary)
+	rawArgs bool            // pass -h/--help through, unless it's the first argument
+	hidden  bool            // left out of the help (ks __complete)
+
+	// complete returns the candidates for the next argument, given the ones
+	// before it (flags left out); nil means nothing is completed
+	complete func(notesDir string, args []string) []string
+}
+
+// commands lists the subcommands in the order "ks --help" shows them
+// (filled in by init, since the help refers back to the list)
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "write", args: "<note> [text]", summary: "Write a note (text from the argument, stdin or the editor)", run: runWriteCommand, usage: printWriteUsage, complete: firstArg(completeNotes)},
+		{name: "append", args: "<note> [text]", summary: "Append to a note", run: runAppendCommand, usage: printAppendUsage, complete: firstArg(completeNotes)},
+		{name: "read", aliases: []string{"view"}, args: "<note>", summary: "Show a note in the viewer (printed when piped)", run: runReadCommand, complete: firstArg(completeNotes)},
+		{name: "rm", aliases: []string{"delete"}, args: "[--force] <note...>", summary: "Move notes to the trash", run: runRmCommand, complete: everyArg(completeNotes)},
+		{name: "ls", aliases: []string{"list"}, args: "[--format f] [pattern...]", summary: "List notes (as text, json, csv, tsv or a template)", run: runLsCommand, usage: printLsUsage, complete: everyArg(completeFolders)},
+		{name: "mv", aliases: []string{"rename"}, args: "[--force] <note> <new>", summary: "Rename or move a note (updating links to it)", run: runMvCommand, complete: completeSource},
+		{name: "cp", aliases: []string{"copy"}, args: "<note> <new>", summary: "Copy a note", run: runCpCommand, complete: completeSource},
+		{name: "new", args: "[--template name] <note>", summary: "Create a note, optionally from a template", run: runNewCommand, usage: printNewUsage, complete: firstArg(completeFolders)},
+		{name: "search", args: "<query...>", summary: "Search notes", run: runSearchCommand, usage: printSearchUsage},
+		{name: "today", args: "[-a text]", summary: "Open today's journal note (or add a timestamped bullet)", run: func(args []string) { runJournalCommand("today", args) }, usage: printJournalUsage},
+		{name: "journal", args: "[date] [-a text]", summary: "Open the journal note for a day", run: func(args []string) { runJournalCommand("journal", args) }, usage: printJournalUsage, complete: oneOf("today", "yesterday", "tomorrow")},
+		{name: "export", args: "[format] [-o output] [--force]", summary: "Export notes (tar.gz, zip, md, jsonl, html)", run: runExportCommand, usage: printExportUsage, complete: firstArg(func(string) []string { return exportFormatNames() })},
+		{name: "import", args: "<path>", summary: "Import from Obsidian, Joplin or Evernote", run: runImportCommand, usage: printImportUsage},
+		{name: "trash", args: "[list|restore|empty]", summary: "Manage deleted notes", run: runTrashCommand, usage: printTrashUsage, complete: completeTrashCommand},
+		{name: "history", args: "<note>", summary: "List saved versions of a note", run: runHistoryCommand, usage: printHistoryUsage, complete: firstArg(completeNotes)},
+		{name: "diff", args: "<note> [#]", summary: "Show changes since a saved version", run: runDiffCommand, usage: printDiffUsage, complete: firstArg(completeNotes)},
+		{name: "restore", args: "<note> <#>", summary: "Roll a note back to a saved version", run: runRestoreCommand, usage: printRestoreUsage, complete: firstArg(completeNotes)},
+		{name: "encrypt", args: "<note...>", summary: "Encrypt notes with a passphrase", run: runEncryptCommand, usage: printEncryptUsage, complete: everyArg(completeNotes)},
+		{name: "decrypt", args: "<note...>", summary: "Turn encrypted notes back into plain text", run: runDecryptCommand, usage: printDecryptUsage, complete: everyArg(completeNotes)},
+		{name: "git", args: "log [note]", summary: "Show the commits of a git-backed notes directory", run: runGitCommand, usage: printGitUsage, rawArgs: true, complete: completeGitCommand},
+		{name: "sync", summary: "Pull and push notes to the git remote", run: runSyncCommand, usage: printSyncUsage},
+		{name: "reindex", summary: "Rebuild the search index", run: func(args []string) { reindexNotes() }},
+		{name: "completion", args: "bash|zsh|fish", summary: "Print a shell completion script", run: runCompletionCommand, usage: printCompletionUsage, complete: oneOf("bash", "zsh", "fish")},
+		{name: "help", args: "[command]", summary: "Show help for ks or a command", run: runHelpCommand, complete: completeHelpCommand},
+		{name: "__complete", run: runCompleteCommand, hidden: true},
+	}
+}
+
+// findCommand looks a subcommand up by name or alias
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+		for _, alias := range cmd.aliases {
+			if alias == name {
+				return cmd, true
+			}
+		}
+	}
+	return command{}, false
+}
+
+// runCommand runs a subcommand, answering --help for it first
+func runCommand(cmd command, args []string) {
+	for i, arg := range args {
+		if cmd.hidden {
+			break
+		}
+		if arg == "--" || (cmd.rawArgs && i > 0) {
+			break
+		}
+		if arg == "-h" || arg == "--help" {
+			cmd.printUsage(os.Stdout)
+			os.Exit(0)
+		}
+	}
+	cmd.run(args)
+}
+
+// printUsage shows a command's help
+func (cmd command) printUsage(w io.Writer) {
+	if cmd.usage != nil {
+		cmd.usage(w)
+		return
+	}
+	fmt.Fprintln(w, strings.TrimSpace("Usage: ks "+cmd.name+" "+cmd.args))
+	fmt.Fprintln(w, "\n"+cmd.summary+".")
+	if len(cmd.aliases) > 0 {
+		fmt.Fprintln(w, "\nAlso: ks "+strings.Join(cmd.aliases, ", ks "))
+	}
+}
+
+// runHelpCommand handles "ks help [command]"
+func runHelpCommand(args []string) {
+	if len(args) == 0 {
+		printUsage(os.Stdout)
+		return
+	}
+	cmd, ok := findCommand(args[0])
+	if !ok {
+		fail(exitUsage, "Unknown command '%s' (see 'ks --help')", args[0])
+	}
+	cmd.printUsage(os.Stdout)
+}
+
+// runWriteCommand handles "ks write <note> [text]" (and ks -w)
+func runWriteCommand(args []string) {
+	fs := flag.NewFlagSet("write", flag.ExitOnError)
+	fs.BoolVar(&encryptWrites, "encrypt", false, "Encrypt the note with a passphrase")
+	fs.Usage = func() { printWriteUsage(os.Stderr) }
+	fs.Parse(args)
+
+	writeNote(noteAndText("Write", fs.Args(), interactiveWrite, printWriteUsage))
+}
+
+// runAppendCommand handles "ks append <note> [text]" (and ks -a)
+func runAppendCommand(args []string) {
+	appendNote(noteAndText("Append", args, interactiveAppend, printAppendUsage))
+}
+
+// noteAndText gets the note and text for write and append: both from the
+// arguments, the text from stdin, or typed in the TUI
+func noteAndText(verb string, args []string, interactive func() (string, string, bool), usage func(io.Writer)) (string, string) {
+	switch len(args) {
+	case 0:
+		// Fully interactive - prompt for the name and the text
+		if !isTTY() {
+			usageError(usage)
+		}
+		filename, note, ok := interactive()
+		if !ok {
+			fail(exitCancelled, "%s cancelled", verb)
+		}
+		return filename, note
+	case 1:
+		if content, ok := readFromStdin(); ok {
+			return args[0], content
+		}
+		// Prompt for the text of the named note
+		checkName(args[0])
+		note, ok := interactiveContent(args[0], "")
+		if !ok {
+			fail(exitCancelled, "%s cancelled", verb)
+		}
+		return args[0], note
+	case 2:
+		return args[0], args[1]
+	}
+	usageError(usage)
+	return "", ""
+}
+
+// printWriteUsage shows the help for "ks write"
+func printWriteUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ks write [--encrypt] <note> <text>")
+	fmt.Fprintln(w, "   or: echo \"text\" | ks write <note>")
+	fmt.Fprintln(w, "   or: ks write <note>      (type the text in the editor)")
+	fmt.Fprintln(w, "   or: ks write             (fully interactive)")
+	fmt.Fprintln(w, "\nCreates or overwrites a note. -w and --write are short for ks write.")
+	fmt.Fprintln(w, "--encrypt encrypts the note with a passphrase (see 'ks encrypt --help').")
+}
+
+// printAppendUsage shows the help for "ks append"
+func printAppendUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ks append <note> <text>")
+	fmt.Fprintln(w, "   or: echo \"text\" | ks append <note>")
+	fmt.Fprintln(w, "   or: ks append <note>     (type the text in the editor)")
+	fmt.Fprintln(w, "   or: ks append            (fully interactive)")
+	fmt.Fprintln(w, "\nAdds text to the end of a note, offering to create it if it doesn't exist.")
+	fmt.Fprintln(w, "-a and --append are short for ks append.")
+}
+
+// runReadCommand handles "ks read <note>" (and ks -r)
+func runReadCommand(args []string) {
+	if len(args) != 1 {
+		usageError(mustCommand("read").printUsage)
+	}
+	readNote(args[0])
+}
+
+// runRmCommand handles "ks rm [--force] <note...>" (and ks -d)
+func runRmCommand(args []string) {
+	fs := flag.NewFlagSet("rm", flag.ExitOnError)
+	var force bool
+	fs.BoolVar(&force, "f", false, "Don't ask for confirmation")
+	fs.BoolVar(&force, "force", false, "Don't ask for confirmation")
+	fs.Usage = func() { mustCommand("rm").printUsage(os.Stderr) }
+	names := parseArgs(fs, args)
+
+	if len(names) == 0 {
+		usageError(mustCommand("rm").printUsage)
+	}
+	deleteNotes(names, force)
+}
+
+// deleteNotes moves notes to the trash after checking they all exist, asking
+// once for all of them unless force is set
+func deleteNotes(names []string, force bool) {
+	if len(names) == 1 {
+		deleteNote(names[0], force)
+		return
+	}
+
+	notesDir := cliNotesDir()
+	for _, filename := range names {
+		existingNote(notesDir, filename)
+	}
+	if !force && !confirm(fmt.Sprintf("Delete %s (%s)?", pluralNotes(len(names)), strings.Join(names, ", "))) {
+		fail(exitCancelled, "Deletion cancelled")
+	}
+	for _, filename := range names {
+		deleteNote(filename, true)
+	}
+}
+
+// runMvCommand handles "ks mv [--force] <note> <new name>"
+func runMvCommand(args []string) {
+	fs := flag.NewFlagSet("mv", flag.ExitOnError)
+	var force bool
+	fs.BoolVar(&force, "f", false, "Replace an existing note")
+	fs.BoolVar(&force, "force", false, "Replace an existing note")
+	fs.Usage = func() { mustCommand("mv").printUsage(os.Stderr) }
+	names := parseArgs(fs, args)
+	if len(names) != 2 {
+		usageError(mustCommand("mv").printUsage)
+	}
+	oldName, newName := names[0], names[1]
+
+	notesDir := cliNotesDir()
+	existingNote(notesDir, oldName)
+	checkName(newName)
+	if oldName == newName {
+		return
+	}
+	if _, err := os.Stat(notePath(notesDir, newName)); err == nil && !force {
+		fail(exitExists, "Note '%s' already exists (use --force to replace it)", newName)
+	}
+
+	relinked, err := renameNoteQuiet(oldName, newName, force)
+	var linkErr *linkError
+	if err != nil && !errors.As(err, &linkErr) {
+		failErr(err, "Could not rename '%s'", oldName)
+	}
+	fmt.Println(theme.Success.Render(fmt.Sprintf("✓ Renamed %s to %s", oldName, newName)))
+	if len(relinked) > 0 {
+		fmt.Println(theme.Muted.Render("  Updated links in " + pluralNotes(len(relinked))))
+	}
+	if linkErr != nil {
+		fmt.Fprintln(os.Stderr, theme.Warning.Render("⚠ Could not update links in "+strings.Join(linkErr.names, ", ")))
+	}
+}
+
+// runCpCommand handles "ks cp <note> <new name>"
+func runCpCommand(args []string) {
+	if len(args) != 2 {
+		usageError(mustCommand("cp").printUsage)
+	}
+	source, target := args[0], args[1]
+
+	notesDir := cliNotesDir()
+	existingNote(notesDir, source)
+	checkName(target)
+
+	content, err := os.ReadFile(notePath(notesDir, source))
+	if err != nil {
+		failErr(err, "Could not read '%s'", source)
+	}
+	targetPath := notePath(notesDir, target)
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		failErr(err, "Could not create folder")
+	}
+	if err := saveNewFile(targetPath, content); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			fail(exitExists, "Note '%s' already exists", target)
+		}
+		failErr(err, "Could not write '%s'", target)
+	}
+	if isEncrypted(content) {
+		protectFile(targetPath)
+	}
+
+	snapshotNote(notesDir, target)
+	updateIndex(notesDir, target)
+	gitCommitCLI(notesDir, fmt.Sprintf("Copy %s to %s", source, target))
+	fmt.Println(theme.Success.Render(fmt.Sprintf("✓ Copied %s to %s", source, target)))
+}
+
+// mustCommand returns a subcommand known to exist
+func mustCommand(name string) command {
+	cmd, _ := findCommand(name)
+	return cmd
+}
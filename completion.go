@@ -0,0 +1,374 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// completionScripts are printed by "ks completion <shell>"
+// They hand the words typed so far to "ks __complete", which prints the candidates.
+var completionScripts = map[string]string{
+	"bash": `# bash completion for ks
+# Add to ~/.bashrc:  source <(ks completion bash)
+
+_ks() {
+    local IFS=$'\n' word
+    local -a candidates
+    candidates=($(ks __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+    COMPREPLY=()
+    for word in "${candidates[@]}"; do
+        COMPREPLY+=("$(printf '%q' "$word")")
+    done
+
+    # Folders end in / - keep typing the note name after them
+    if [[ ${#COMPREPLY[@]} -eq 1 && ${COMPREPLY[0]} == */ ]]; then
+        compopt -o nospace
+    fi
+}
+
+complete -F _ks ks
+`,
+	"zsh": `#compdef ks
+# zsh completion for ks
+# Add to ~/.zshrc:  source <(ks completion zsh)
+# or save it as _ks in a directory of your $fpath
+
+_ks() {
+    local -a notes folders
+    local line
+    for line in "${(@f)$(ks __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}"; do
+        if [[ $line == */ ]]; then
+            folders+=("$line")
+        elif [[ -n $line ]]; then
+            notes+=("$line")
+        fi
+    done
+
+    # Folders end in / - keep typing the note name after them
+    compadd -S '' -- "${folders[@]}"
+    compadd -- "${notes[@]}"
+}
+
+if [[ $funcstack[1] == _ks ]]; then
+    _ks "$@"
+else
+    compdef _ks ks
+fi
+`,
+	"fish": `# fish completion for ks
+# Add to ~/.config/fish/config.fish:  ks completion fish | source
+# or save it as ~/.config/fish/completions/ks.fish
+
+function __ks_complete
+    set -l tokens (commandline -opc)
+    ks __complete $tokens[2..-1] (commandline -ct) 2>/dev/null
+end
+
+complete -c ks -f -a '(__ks_complete)'
+`,
+}
+
+// runCompletionCommand handles "ks completion bash|zsh|fish"
+func runCompletionCommand(args []string) {
+	if len(args) != 1 {
+		usageError(printCompletionUsage)
+	}
+	script, ok := completionScripts[args[0]]
+	if !ok {
+		fail(exitUsage, "Unknown shell '%s' (use bash, zsh or fish)", args[0])
+	}
+	fmt.Print(script)
+}
+
+// printCompletionUsage shows the help for "ks completion"
+func printCompletionUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ks completion bash|zsh|fish")
+	fmt.Fprintln(w, "\nPrints a shell completion script. Commands, note names, folders, tags and")
+	fmt.Fprintln(w, "templates are completed from the notes directory as you type.")
+	fmt.Fprintln(w, "\nSetup:")
+	fmt.Fprintln(w, "  bash   echo 'source <(ks completion bash)' >> ~/.bashrc")
+	fmt.Fprintln(w, "  zsh    echo 'source <(ks completion zsh)' >> ~/.zshrc")
+	fmt.Fprintln(w, "  fish   ks completion fish > ~/.config/fish/completions/ks.fish")
+}
+
+// valueFlags are the flags that take a value, so the word after them isn't an argument
+var valueFlags = map[string]bool{
+	"-t": true, "--tag": true,
+	"-T": true, "--template": true,
+	"-f": true, "--format": true,
+	"-n": true, "--limit": true,
+	"-o": true, "--output": true,
+	"-a": true, "--append": true,
+	"--sort": true, "--var": true, "--as": true, "--from": true, "--into": true,
+}
+
+// runCompleteCommand handles the hidden "ks __complete <words...>": the words
+// after "ks" up to the one being typed (possibly empty), printing the candidates
+// for the last one, one per line
+func runCompleteCommand(args []string) {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	words, current := args[:len(args)-1], args[len(args)-1]
+
+	notesDir, err := getNotesDir()
+	if err != nil {
+		return
+	}
+	for _, candidate := range completeWords(notesDir, words, current) {
+		if strings.HasPrefix(candidate, current) {
+			fmt.Println(candidate)
+		}
+	}
+}
+
+// completeWords returns the candidates for the word after words
+func completeWords(notesDir string, words []string, current string) []string {
+	// Skip the global flags, noting a legacy command flag such as -r
+	legacy := ""
+	for len(words) > 0 && strings.HasPrefix(words[0], "-") {
+		switch words[0] {
+		case "-w", "--write", "-a", "--append", "-r", "--read", "-d", "--delete", "-s", "--search":
+			legacy = words[0]
+		case "-t", "--tag":
+			if len(words) == 1 {
+				return completeTags(notesDir)
+			}
+			words = words[1:]
+		}
+		words = words[1:]
+	}
+
+	var cmd command
+	switch legacy {
+	case "-w", "--write":
+		cmd = mustCommand("write")
+	case "-a", "--append":
+		cmd = mustCommand("append")
+	case "-r", "--read":
+		cmd = mustCommand("read")
+	case "-d", "--delete":
+		cmd = mustCommand("rm")
+	case "-s", "--search":
+		return nil
+	default:
+		if len(words) == 0 {
+			if strings.HasPrefix(current, "-") {
+				return globalFlags()
+			}
+			return commandNames()
+		}
+		var ok bool
+		if cmd, ok = findCommand(words[0]); !ok {
+			return nil
+		}
+		words = words[1:]
+	}
+
+	// The value of a flag
+	if len(words) > 0 && valueFlags[words[len(words)-1]] {
+		return completeFlagValue(notesDir, cmd.name, words[len(words)-1])
+	}
+	if cmd.complete == nil || strings.HasPrefix(current, "-") {
+		return nil
+	}
+
+	// The arguments before the one being completed, without the flags
+	var positional []string
+	for i := 0; i < len(words); i++ {
+		if strings.HasPrefix(words[i], "-") {
+			if valueFlags[words[i]] {
+				i++
+			}
+			continue
+		}
+		positional = append(positional, words[i])
+	}
+	return cmd.complete(notesDir, positional)
+}
+
+// completeFlagValue returns the candidates for the value of a command's flag
+func completeFlagValue(notesDir, cmd, flagName string) []string {
+	switch flagName {
+	case "-t", "--tag":
+		return completeTags(notesDir)
+	case "-T", "--template":
+		return listTemplates(notesDir)
+	case "--sort":
+		return []string{"name", "date", "size"}
+	case "--into":
+		return completeFolders(notesDir)
+	case "-f", "--format":
+		switch cmd {
+		case "ls":
+			return []string{"json", "csv", "tsv"}
+		case "export":
+			return exportFormatNames()
+		}
+	}
+	return nil
+}
+
+// globalFlags returns the flags of ks itself, as they are typed
+func globalFlags() []string {
+	var flags []string
+	flag.VisitAll(func(f *flag.Flag) {
+		if len(f.Name) == 1 {
+			flags = append(flags, "-"+f.Name)
+		} else {
+			flags = append(flags, "--"+f.Name)
+		}
+	})
+	return flags
+}
+
+// commandNames returns the names of the subcommands shown in the help
+func commandNames() []string {
+	var names []string
+	for _, cmd := range commands {
+		if !cmd.hidden {
+			names = append(names, cmd.name)
+		}
+	}
+	return names
+}
+
+// completeNotes returns every note name, and every folder with a trailing slash
+func completeNotes(notesDir string) []string {
+	notes, err := walkNoteFiles(notesDir)
+	if err != nil {
+		return nil
+	}
+	names := noteFolders(notes)
+	for _, note := range notes {
+		names = append(names, note.name)
+	}
+	return names
+}
+
+// completeFolders returns the folders that hold notes, with a trailing slash
+func completeFolders(notesDir string) []string {
+	notes, err := walkNoteFiles(notesDir)
+	if err != nil {
+		return nil
+	}
+	return noteFolders(notes)
+}
+
+// noteFolders returns the folders of the notes and their parents, with a trailing slash
+func noteFolders(notes []noteInfo) []string {
+	seen := make(map[string]bool)
+	var folders []string
+	for _, note := range notes {
+		for dir := path.Dir(note.name); dir != "." && !seen[dir]; dir = path.Dir(dir) {
+			seen[dir] = true
+			folders = append(folders, dir+"/")
+		}
+	}
+	sort.Strings(folders)
+	return folders
+}
+
+// completeTags returns the tags used by the notes
+func completeTags(notesDir string) []string {
+	notes, err := walkNotes(notesDir)
+	if err != nil {
+		return nil
+	}
+	return collectTags(notes)
+}
+
+// completeTrash returns the original names of the notes in the trash
+func completeTrash(notesDir string) []string {
+	entries, err := listTrash(notesDir)
+	if err != nil {
+		return nil
+	}
+	// A note deleted twice is in the trash twice
+	seen := make(map[string]bool)
+	var names []string
+	for _, entry := range entries {
+		if !seen[entry.Path] {
+			seen[entry.Path] = true
+			names = append(names, entry.Path)
+		}
+	}
+	return names
+}
+
+// exportFormatNames returns the export formats, sorted
+func exportFormatNames() []string {
+	var names []string
+	for name := range exportFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// firstArg completes only the first argument of a command with the given function
+func firstArg(complete func(notesDir string) []string) func(string, []string) []string {
+	return func(notesDir string, args []string) []string {
+		if len(args) > 0 {
+			return nil
+		}
+		return complete(notesDir)
+	}
+}
+
+// everyArg completes every argument of a command with the given function
+func everyArg(complete func(notesDir string) []string) func(string, []string) []string {
+	return func(notesDir string, args []string) []string {
+		return complete(notesDir)
+	}
+}
+
+// oneOf completes the first argument from a fixed list
+func oneOf(list ...string) func(string, []string) []string {
+	return firstArg(func(string) []string { return list })
+}
+
+// completeSource completes a note, then the folder to move or copy it to
+func completeSource(notesDir string, args []string) []string {
+	switch len(args) {
+	case 0:
+		return completeNotes(notesDir)
+	case 1:
+		return completeFolders(notesDir)
+	}
+	return nil
+}
+
+// completeTrashCommand completes "ks trash restore <note>"
+func completeTrashCommand(notesDir string, args []string) []string {
+	switch {
+	case len(args) == 0:
+		return []string{"list", "restore", "empty"}
+	case len(args) == 1 && args[0] == "restore":
+		return completeTrash(notesDir)
+	}
+	return nil
+}
+
+// completeGitCommand completes "ks git log <note>"
+func completeGitCommand(notesDir string, args []string) []string {
+	switch {
+	case len(args) == 0:
+		return []string{"log"}
+	case len(args) == 1 && args[0] == "log":
+		return completeNotes(notesDir)
+	}
+	return nil
+}
+
+// completeHelpCommand completes "ks help <command>"
+func completeHelpCommand(notesDir string, args []string) []string {
+	if len(args) > 0 {
+		return nil
+	}
+	return commandNames()
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Config holds the settings persisted in the config file
+type Config struct {
+	Theme    string // theme name, e.g. "Ocean"
+	Sort     string // default list sort: "name", "date" or "size"
+	Preview  bool   // show the preview panel in the list view
+	Markdown bool   // render Markdown in the viewer and preview panel (false = raw text)
+	NotesDir string // where notes are stored ("" = ~/.local/share/ks)
+
+	ExternalEditor bool // edit notes in $VISUAL/$EDITOR instead of the built-in editor
+
+	TrashRetentionDays int // deleted notes are purged from the trash after this many days (0 = never)
+
+	TemplatesDir string // folder "ks new --template" and the New Note form take templates from
+
+	JournalDir      string // folder "ks today" keeps the journal in ("" = the notes directory itself)
+	JournalFormat   string // journal note names, strftime-style: "%Y-%m-%d" gives 2026-10-18.md
+	JournalTemplate string // template new journal notes start from ("" = a heading with the date)
+
+	Git       bool   // keep the notes directory in git and commit every change
+	GitRemote string // remote used by "ks sync" (URL or path; "" = use the repository's origin)
+}
+
+// Global config instance, loaded at startup
+var config = defaultConfig()
+
+// defaultConfig returns the settings used when there is no config file
+func defaultConfig() Config {
+	return Config{
+		Theme:    "Purple (Default)",
+		Sort:     "name",
+		Preview:  true,
+		Markdown: true,
+
+		TrashRetentionDays: 30,
+
+		TemplatesDir: "templates",
+
+		JournalDir:    "journal",
+		JournalFormat: "%Y-%m-%d",
+	}
+}
+
+// getConfigPath returns the config file location: $XDG_CONFIG_HOME/ks/config.toml
+// (falling back to ~/.config/ks/config.toml)
+func getConfigPath() (string, error) {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+
+	return filepath.Join(configHome, "ks", "config.toml"), nil
+}
+
+// loadConfig reads the config file on top of the defaults
+// A missing file is not an error
+func loadConfig() (Config, error) {
+	cfg := defaultConfig()
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return cfg, err
+	}
+
+	file, err := os.Open(configPath)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		key, value, ok, err := parseConfigLine(scanner.Text())
+		if err != nil {
+			return cfg, fmt.Errorf("%s:%d: %v", configPath, lineNum, err)
+		}
+		if !ok {
+			continue
+		}
+		if err := cfg.set(key, value); err != nil {
+			return cfg, fmt.Errorf("%s:%d: %v", configPath, lineNum, err)
+		}
+	}
+
+	return cfg, scanner.Err()
+}
+
+// parseConfigLine parses a `key = value` line of the config file
+// Returns ok=false for blank lines, comments and [section] headers
+func parseConfigLine(line string) (string, string, bool, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") {
+		return "", "", false, nil
+	}
+
+	key, value, found := strings.Cut(line, "=")
+	if !found {
+		return "", "", false, fmt.Errorf("expected key = value")
+	}
+	key = strings.TrimSpace(key)
+	value = strings.TrimSpace(value)
+
+	switch {
+	case strings.HasPrefix(value, `"`):
+		// Basic string - find the closing quote, then allow a trailing comment
+		end := closingQuote(value)
+		if end < 0 {
+			return "", "", false, fmt.Errorf("unterminated string for %s", key)
+		}
+		unquoted, err := strconv.Unquote(value[:end+1])
+		if err != nil {
+			return "", "", false, fmt.Errorf("invalid string for %s", key)
+		}
+		return key, unquoted, true, nil
+
+	case strings.HasPrefix(value, "'"):
+		// Literal string - no escapes
+		end := strings.Index(value[1:], "'")
+		if end < 0 {
+			return "", "", false, fmt.Errorf("unterminated string for %s", key)
+		}
+		return key, value[1 : end+1], true, nil
+	}
+
+	// Bare value (bool or number) with an optional trailing comment
+	if before, _, ok := strings.Cut(value, "#"); ok {
+		value = strings.TrimSpace(before)
+	}
+	return key, value, true, nil
+}
+
+// closingQuote returns the index of the quote that ends a basic string starting at s[0]
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// set applies a single config value by key
+func (c *Config) set(key, value string) error {
+	switch key {
+	case "theme":
+		name, ok := findTheme(value)
+		if !ok {
+			return fmt.Errorf("unknown theme %q", value)
+		}
+		c.Theme = name
+	case "sort":
+		if value != "name" && value != "date" && value != "size" {
+			return fmt.Errorf("sort must be \"name\", \"date\" or \"size\"")
+		}
+		c.Sort = value
+	case "preview":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("preview must be true or false")
+		}
+		c.Preview = b
+	case "markdown":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("markdown must be true or false")
+		}
+		c.Markdown = b
+	case "notes_dir":
+		c.NotesDir = value
+	case "external_editor":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("external_editor must be true or false")
+		}
+		c.ExternalEditor = b
+	case "trash_retention_days":
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 {
+			return fmt.Errorf("trash_retention_days must be a number of days (0 = keep forever)")
+		}
+		c.TrashRetentionDays = days
+	case "templates_dir":
+		c.TemplatesDir = strings.TrimRight(value, "/")
+	case "journal_dir":
+		c.JournalDir = strings.Trim(value, "/")
+	case "journal_format":
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("journal_format must not be empty")
+		}
+		c.JournalFormat = value
+	case "journal_template":
+		c.JournalTemplate = value
+	case "git":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("git must be true or false")
+		}
+		c.Git = b
+	case "git_remote":
+		c.GitRemote = value
+	default:
+		// Unknown keys are ignored so newer config files still load
+	}
+	return nil
+}
+
+// findTheme matches a theme name case-insensitively ("ocean", "purple")
+func findTheme(name string) (string, bool) {
+	for themeName := range themes {
+		if strings.EqualFold(themeName, name) {
+			return themeName, true
+		}
+	}
+	for themeName := range themes {
+		if strings.HasPrefix(strings.ToLower(themeName), strings.ToLower(name)+" ") {
+			return themeName, true
+		}
+	}
+	return "", false
+}
+
+// saveConfigValue writes one setting back to the config file
+// Existing lines are updated in place so comments and other settings are kept
+func saveConfigValue(key, value string) error {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	newLine := key + " = " + value
+	var lines []string
+	if len(data) > 0 {
+		lines = strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	} else {
+		lines = []string{"# ks configuration"}
+	}
+
+	replaced := false
+	for i, line := range lines {
+		lineKey, _, ok, _ := parseConfigLine(line)
+		if ok && lineKey == key {
+			lines[i] = newLine
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		lines = append(lines, newLine)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return err
+	}
+
+	return saveFile(configPath, []byte(strings.Join(lines, "\n")+"\n"))
+}
+
+// quoteConfigString formats a string as a TOML basic string
+func quoteConfigString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// expandPath expands a leading ~ and environment variables in a configured path
+func expandPath(p string) (string, error) {
+	p = os.ExpandEnv(p)
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		p = filepath.Join(home, p[1:])
+	}
+	return filepath.Abs(p)
+}
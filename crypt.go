@@ -0,0 +1,502 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Encrypted notes keep their name and are stored as a text envelope:
+//
+//	-----BEGIN KS ENCRYPTED NOTE-----
+//	Version: 1
+//	KDF: pbkdf2-sha256 600000
+//	Salt: <base64>
+//
+//	<base64 of the AES-256-GCM nonce and ciphertext, wrapped at 64 columns>
+//	-----END KS ENCRYPTED NOTE-----
+//
+// The key is derived from the passphrase; the header lines are authenticated
+// along with the content, so they can't be changed without the passphrase.
+const (
+	envelopeBegin      = "-----BEGIN KS ENCRYPTED NOTE-----"
+	envelopeEnd        = "-----END KS ENCRYPTED NOTE-----"
+	envelopeIterations = 600000
+
+	// maxEnvelopeIterations bounds the count read from a header, so a tampered
+	// note can't make every unlock attempt take minutes
+	maxEnvelopeIterations = 10 * envelopeIterations
+)
+
+// errWrongPassphrase is returned when a note can't be decrypted with the passphrase given
+var errWrongPassphrase = errors.New("wrong passphrase")
+
+// errLocked is returned when an encrypted note hasn't been unlocked yet
+var errLocked = errors.New("the note is encrypted")
+
+// isEncrypted reports whether a note's content is an encrypted envelope
+// (with either line ending, in case an editor converted them)
+func isEncrypted(content []byte) bool {
+	rest, ok := bytes.CutPrefix(content, []byte(envelopeBegin))
+	return ok && (bytes.HasPrefix(rest, []byte("\n")) || bytes.HasPrefix(rest, []byte("\r\n")))
+}
+
+// envelopeKey derives the AES key for a passphrase
+func envelopeKey(passphrase string, salt []byte, iterations int) ([]byte, error) {
+	return pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+}
+
+// encryptNote seals a note's content with a passphrase
+func encryptNote(plaintext []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	header := fmt.Sprintf("%s\nVersion: 1\nKDF: pbkdf2-sha256 %d\nSalt: %s\n",
+		envelopeBegin, envelopeIterations, base64.StdEncoding.EncodeToString(salt))
+
+	key, err := envelopeKey(passphrase, salt, envelopeIterations)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := gcm.Seal(nonce, nonce, plaintext, []byte(header))
+
+	var envelope strings.Builder
+	envelope.WriteString(header + "\n")
+	encoded := base64.StdEncoding.EncodeToString(sealed)
+	for len(encoded) > 64 {
+		envelope.WriteString(encoded[:64] + "\n")
+		encoded = encoded[64:]
+	}
+	envelope.WriteString(encoded + "\n" + envelopeEnd + "\n")
+	return []byte(envelope.String()), nil
+}
+
+// decryptNote opens an envelope written by encryptNote
+func decryptNote(content []byte, passphrase string) ([]byte, error) {
+	text := strings.ReplaceAll(string(content), "\r\n", "\n")
+	header, body, ok := strings.Cut(text, "\n\n")
+	if !ok || !isEncrypted(content) {
+		return nil, fmt.Errorf("not an encrypted note")
+	}
+	header += "\n"
+
+	fields := make(map[string]string)
+	for _, line := range strings.Split(header, "\n")[1:] {
+		if key, value, ok := strings.Cut(line, ": "); ok {
+			fields[key] = value
+		}
+	}
+	if fields["Version"] != "1" {
+		return nil, fmt.Errorf("unsupported encrypted note version %q", fields["Version"])
+	}
+	kdf := strings.Fields(fields["KDF"])
+	if len(kdf) != 2 || kdf[0] != "pbkdf2-sha256" {
+		return nil, fmt.Errorf("unsupported key derivation %q", fields["KDF"])
+	}
+	iterations, err := strconv.Atoi(kdf[1])
+	if err != nil || iterations < 1 || iterations > maxEnvelopeIterations {
+		return nil, fmt.Errorf("invalid key derivation %q", fields["KDF"])
+	}
+	salt, err := base64.StdEncoding.DecodeString(fields["Salt"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt")
+	}
+
+	body, _, ok = strings.Cut(body, envelopeEnd)
+	if !ok {
+		return nil, fmt.Errorf("encrypted note is truncated")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(body), ""))
+	if err != nil {
+		return nil, fmt.Errorf("encrypted note is damaged: %v", err)
+	}
+
+	key, err := envelopeKey(passphrase, salt, iterations)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted note is truncated")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(header))
+	if err != nil {
+		return nil, errWrongPassphrase
+	}
+	return plaintext, nil
+}
+
+// unlockedNotes remembers the passphrases of notes unlocked in this session, by
+// note name, so they aren't asked for again. They are never written to disk.
+var unlockedNotes = make(map[string]string)
+
+// readNoteText reads a note, decrypting it if it is encrypted and was unlocked
+// Returns the text and the passphrase it is encrypted with ("" = plaintext note).
+func readNoteText(notesDir, name string) (string, string, error) {
+	content, err := os.ReadFile(notePath(notesDir, name))
+	if err != nil {
+		return "", "", err
+	}
+	return openNoteText(name, content)
+}
+
+// openNoteText decrypts a note's content with the passphrase it was unlocked with
+func openNoteText(name string, content []byte) (string, string, error) {
+	if !isEncrypted(content) {
+		return string(content), "", nil
+	}
+	passphrase, ok := unlockedNotes[name]
+	if !ok {
+		return "", "", errLocked
+	}
+	plaintext, err := decryptNote(content, passphrase)
+	if err != nil {
+		return "", "", err
+	}
+	return string(plaintext), passphrase, nil
+}
+
+// isEncryptedNote reports whether a note on disk is encrypted
+func isEncryptedNote(notesDir, name string) bool {
+	file, err := os.Open(notePath(notesDir, name))
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	head := make([]byte, len(envelopeBegin)+2)
+	n, _ := file.Read(head)
+	return isEncrypted(head[:n])
+}
+
+// lockedPreview is shown instead of an encrypted note that hasn't been unlocked
+func lockedPreview(name string) string {
+	return theme.Warning.Render("🔒 "+name+" is encrypted") + "\n\n" +
+		theme.Muted.Render("Press Enter or v to unlock it with its passphrase.")
+}
+
+// passphraseModel asks for a passphrase: to unlock an encrypted note (checking
+// it against the note), or a new one for encrypting, typed twice
+type passphraseModel struct {
+	title      string
+	input      textinput.Model
+	locked     []byte // encrypted content to unlock (nil = choosing a new passphrase)
+	first      string // first entry of a new passphrase, waiting for the repeat
+	plaintext  []byte // the unlocked content
+	passphrase string // the accepted passphrase ("" = cancelled)
+	err        string
+	quitting   bool
+	width      int
+	height     int
+}
+
+func newPassphraseModel(title string, locked []byte) passphraseModel {
+	ti := textinput.New()
+	ti.EchoMode = textinput.EchoPassword
+	ti.EchoCharacter = '•'
+	ti.Focus()
+	return passphraseModel{title: title, input: ti, locked: locked}
+}
+
+func (m passphraseModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m passphraseModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			m.quitting = true
+			return m, screenDone
+
+		case "enter":
+			value := m.input.Value()
+			m.input.SetValue("")
+			if value == "" {
+				m.err = "The passphrase can't be empty"
+				return m, nil
+			}
+
+			if m.locked != nil {
+				plaintext, err := decryptNote(m.locked, value)
+				if err != nil {
+					m.err = "Could not unlock the note: " + err.Error()
+					return m, nil
+				}
+				m.plaintext = plaintext
+			} else if m.first == "" {
+				m.first = value
+				m.err = ""
+				return m, nil
+			} else if value != m.first {
+				m.first = ""
+				m.err = "The passphrases don't match - try again"
+				return m, nil
+			}
+
+			m.passphrase = value
+			m.quitting = true
+			return m, screenDone
+		}
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+func (m passphraseModel) View() string {
+	if m.quitting {
+		return ""
+	}
+
+	prompt := "Passphrase:"
+	if m.locked == nil && m.first != "" {
+		prompt = "Repeat the passphrase:"
+	} else if m.locked == nil {
+		prompt = "New passphrase:"
+	}
+
+	var content strings.Builder
+	content.WriteString(theme.Primary.Render("🔒 "+m.title) + "\n\n")
+	content.WriteString(theme.Secondary.Render(prompt) + "\n")
+	content.WriteString(m.input.View() + "\n")
+	if m.err != "" {
+		content.WriteString("\n" + theme.Error.Render("✗ "+m.err) + "\n")
+	}
+	content.WriteString("\n" + theme.Muted.Render("Enter to continue • Esc to cancel"))
+
+	contentStr := content.String()
+	contentHeight := strings.Count(contentStr, "\n") + 1
+	if m.height > contentHeight {
+		contentStr = strings.Repeat("\n", (m.height-contentHeight)/2) + contentStr
+	}
+	return lipgloss.NewStyle().Width(m.width).Align(lipgloss.Center).Render(contentStr)
+}
+
+// askPassphrase gets a passphrase on the command line: from $KS_PASSPHRASE, or
+// typed in the terminal. With locked content the passphrase must unlock it;
+// without, a new passphrase is typed twice. Exits if there is none.
+func askPassphrase(title string, locked []byte) (string, []byte) {
+	if passphrase := os.Getenv("KS_PASSPHRASE"); passphrase != "" {
+		if locked == nil {
+			return passphrase, nil
+		}
+		plaintext, err := decryptNote(locked, passphrase)
+		if err != nil {
+			fail(exitFailure, "Could not unlock the note with $KS_PASSPHRASE: %v", err)
+		}
+		return passphrase, plaintext
+	}
+
+	if !isTTY() {
+		fail(exitFailure, "A passphrase is needed: run in a terminal or set $KS_PASSPHRASE")
+	}
+	app := newAppModel(passphraseScreen)
+	app.passphrase = newPassphraseModel(title, locked)
+	app = runApp(app)
+	if app.passphrase.passphrase == "" {
+		fail(exitCancelled, "Cancelled")
+	}
+	return app.passphrase.passphrase, app.passphrase.plaintext
+}
+
+// sealForWrite returns the bytes to write for a note's new content: encrypted
+// when the note already is (with its passphrase) or when encryptWrites is set
+func sealForWrite(notesDir, filename string, content []byte) ([]byte, error) {
+	existing, err := os.ReadFile(notePath(notesDir, filename))
+	var passphrase string
+	switch {
+	case err == nil && isEncrypted(existing):
+		passphrase, _ = askPassphrase("Unlock "+filename, existing)
+	case encryptWrites:
+		passphrase, _ = askPassphrase("Encrypt "+filename, nil)
+	default:
+		return content, nil
+	}
+	return encryptNote(content, passphrase)
+}
+
+// encryptWrites encrypts the notes written on the command line (--encrypt)
+var encryptWrites bool
+
+// protectFile makes an encrypted note readable by its owner only
+func protectFile(filePath string) {
+	os.Chmod(filePath, 0600)
+}
+
+// runEncryptCommand handles "ks encrypt <note...>"
+func runEncryptCommand(args []string) {
+	if len(args) == 0 {
+		usageError(printEncryptUsage)
+	}
+
+	notesDir := cliNotesDir()
+	contents, code := readConvertible(notesDir, args, false)
+	passphrase, _ := askPassphrase("Encrypt "+pluralNotes(len(contents)), nil)
+
+	encrypted := 0
+	for _, name := range args {
+		content, ok := contents[name]
+		if !ok {
+			continue
+		}
+		// saveNote drops the plain text versions from the history
+		sealed, err := encryptNote(content, passphrase)
+		if err == nil {
+			err = saveNote(notesDir, name, sealed, "Encrypt "+name)
+		}
+		if err != nil && !isCommitError(err) {
+			printError("Could not encrypt '%s': %v", name, err)
+			code = exitIO
+			continue
+		}
+		checkSaved(err, "")
+		fmt.Println(theme.Success.Render("✓ Encrypted " + name))
+		encrypted++
+	}
+
+	// Git keeps every earlier commit, and ks doesn't rewrite the repository
+	if config.Git && encrypted > 0 {
+		fmt.Fprintln(os.Stderr, theme.Warning.Render("⚠ Earlier plain text versions are still in the git history - rewrite it (e.g. with git filter-repo) if that matters"))
+	}
+	if code != 0 {
+		os.Exit(code)
+	}
+}
+
+// runDecryptCommand handles "ks decrypt <note...>"
+func runDecryptCommand(args []string) {
+	if len(args) == 0 {
+		usageError(printDecryptUsage)
+	}
+
+	notesDir := cliNotesDir()
+	contents, code := readConvertible(notesDir, args, true)
+
+	// One passphrase for all the notes, checked against the first
+	var first []byte
+	for _, name := range args {
+		if content, ok := contents[name]; ok {
+			first = content
+			break
+		}
+	}
+	passphrase, _ := askPassphrase("Decrypt "+pluralNotes(len(contents)), first)
+
+	for _, name := range args {
+		content, ok := contents[name]
+		if !ok {
+			continue
+		}
+		plaintext, err := decryptNote(content, passphrase)
+		if err == nil {
+			err = saveNote(notesDir, name, plaintext, "Decrypt "+name)
+		}
+		if err != nil && !isCommitError(err) {
+			printError("Could not decrypt '%s': %v", name, err)
+			code = exitIO
+			continue
+		}
+		checkSaved(err, "")
+		fmt.Println(theme.Success.Render("✓ Decrypted " + name))
+	}
+	if code != 0 {
+		os.Exit(code)
+	}
+}
+
+// readConvertible reads the notes for "ks encrypt" (encrypted=false) or "ks
+// decrypt" (encrypted=true), reporting the ones that are missing or already converted
+// Returns the exit code for the notes that were skipped (0 if none were); exits if all were.
+func readConvertible(notesDir string, names []string, encrypted bool) (map[string][]byte, int) {
+	contents := make(map[string][]byte)
+	code := 0
+	for _, name := range names {
+		if err := validateFilename(name); err != nil {
+			printError("Invalid note name '%s': %v", name, err)
+			code = exitInvalid
+			continue
+		}
+		content, err := os.ReadFile(notePath(notesDir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				printError("Note '%s' not found", name)
+				code = exitNotFound
+			} else {
+				printError("Could not read '%s': %v", name, err)
+				code = exitIO
+			}
+			continue
+		}
+		if isEncrypted(content) != encrypted {
+			if encrypted {
+				fmt.Fprintln(os.Stderr, theme.Warning.Render(fmt.Sprintf("⚠ '%s' is not encrypted", name)))
+			} else {
+				fmt.Fprintln(os.Stderr, theme.Warning.Render(fmt.Sprintf("⚠ '%s' is already encrypted", name)))
+			}
+			code = exitFailure
+			continue
+		}
+		contents[name] = content
+	}
+	if len(contents) == 0 {
+		os.Exit(code)
+	}
+	return contents, code
+}
+
+// printEncryptUsage shows the help for "ks encrypt"
+func printEncryptUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ks encrypt <note...>")
+	fmt.Fprintln(w, "\nEncrypts notes in place with a passphrase (AES-256-GCM, key derived with")
+	fmt.Fprintln(w, "PBKDF2-SHA256). Their saved versions are removed, since those are not encrypted.")
+	fmt.Fprintln(w, "With git = true, earlier commits still hold the plain text: rewrite the")
+	fmt.Fprintln(w, "repository's history if that matters. The passphrase is asked for, or taken")
+	fmt.Fprintln(w, "from $KS_PASSPHRASE.")
+}
+
+// printDecryptUsage shows the help for "ks decrypt"
+func printDecryptUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ks decrypt <note...>")
+	fmt.Fprintln(w, "\nTurns encrypted notes back into plain text. The passphrase is asked for,")
+	fmt.Fprintln(w, "or taken from $KS_PASSPHRASE. Use 'ks read <note>' to read one without decrypting it.")
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// sealedNote encrypts a note for the tests, failing them on error
+func sealedNote(t *testing.T, plaintext, passphrase string) string {
+	t.Helper()
+	sealed, err := encryptNote([]byte(plaintext), passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(sealed)
+}
+
+func TestEncryptNoteRoundTrip(t *testing.T) {
+	for _, plaintext := range []string{
+		"",
+		"one line",
+		"# Title\n\nA longer note that needs more than one line of base64 in the envelope.\n",
+		"windows\r\nline endings\r\n",
+	} {
+		sealed := sealedNote(t, plaintext, "correct horse")
+		if !isEncrypted([]byte(sealed)) {
+			t.Fatalf("encryptNote(%q) isn't recognised as encrypted:\n%s", plaintext, sealed)
+		}
+		if strings.Contains(sealed, plaintext) && plaintext != "" {
+			t.Errorf("encryptNote(%q) contains the plain text", plaintext)
+		}
+
+		got, err := decryptNote([]byte(sealed), "correct horse")
+		if err != nil {
+			t.Fatalf("decryptNote: %v", err)
+		}
+		if string(got) != plaintext {
+			t.Errorf("round trip of %q gave %q", plaintext, got)
+		}
+	}
+}
+
+func TestDecryptNoteWrongPassphrase(t *testing.T) {
+	sealed := sealedNote(t, "secret", "correct horse")
+	if _, err := decryptNote([]byte(sealed), "battery staple"); !errors.Is(err, errWrongPassphrase) {
+		t.Errorf("decryptNote with the wrong passphrase = %v, want errWrongPassphrase", err)
+	}
+}
+
+func TestDecryptNoteCRLF(t *testing.T) {
+	// An envelope whose line endings were converted, e.g. by a Windows editor
+	sealed := sealedNote(t, "secret\n", "correct horse")
+	crlf := strings.ReplaceAll(sealed, "\n", "\r\n")
+
+	got, err := decryptNote([]byte(crlf), "correct horse")
+	if err != nil {
+		t.Fatalf("decryptNote of a CRLF envelope: %v", err)
+	}
+	if string(got) != "secret\n" {
+		t.Errorf("decryptNote of a CRLF envelope = %q, want %q", got, "secret\n")
+	}
+}
+
+func TestDecryptNoteTampered(t *testing.T) {
+	sealed := sealedNote(t, "pay alice 10", "correct horse")
+	header, body, _ := strings.Cut(sealed, "\n\n")
+
+	// Flip one base64 character of the ciphertext
+	flipped := []byte(body)
+	if flipped[10] == 'A' {
+		flipped[10] = 'B'
+	} else {
+		flipped[10] = 'A'
+	}
+
+	tests := []struct {
+		name     string
+		envelope string
+	}{
+		{"body", header + "\n\n" + string(flipped)},
+		{"extra header line", strings.Replace(header, "Version: 1\n", "Version: 1\nNote: hi\n", 1) + "\n\n" + body},
+		{"iteration count", strings.Replace(header, fmt.Sprint(envelopeIterations), fmt.Sprint(envelopeIterations+1), 1) + "\n\n" + body},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.envelope == sealed {
+				t.Fatal("the envelope wasn't changed")
+			}
+			if _, err := decryptNote([]byte(tt.envelope), "correct horse"); !errors.Is(err, errWrongPassphrase) {
+				t.Errorf("decryptNote of a tampered envelope = %v, want errWrongPassphrase", err)
+			}
+		})
+	}
+}
+
+func TestDecryptNoteInvalid(t *testing.T) {
+	sealed := sealedNote(t, "secret", "correct horse")
+	header, body, _ := strings.Cut(sealed, "\n\n")
+
+	tests := []struct {
+		name     string
+		envelope string
+	}{
+		{"not encrypted", "just a note\n"},
+		{"no end line", strings.TrimSuffix(sealed, envelopeEnd+"\n")},
+		{"header only", header + "\n"},
+		{"body cut short", header + "\n\nAAAA\n" + envelopeEnd + "\n"},
+		{"damaged base64", header + "\n\n!!!!\n" + envelopeEnd + "\n"},
+		{"unknown version", strings.Replace(header, "Version: 1", "Version: 2", 1) + "\n\n" + body},
+		{"unknown kdf", strings.Replace(header, "pbkdf2-sha256", "scrypt", 1) + "\n\n" + body},
+		{"too many iterations", strings.Replace(header, fmt.Sprint(envelopeIterations), "50000000", 1) + "\n\n" + body},
+		{"zero iterations", strings.Replace(header, fmt.Sprint(envelopeIterations), "0", 1) + "\n\n" + body},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decryptNote([]byte(tt.envelope), "correct horse")
+			if err == nil || errors.Is(err, errWrongPassphrase) {
+				t.Errorf("decryptNote = %v, want an invalid envelope error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// exportFormats lists the supported export formats and their default file extensions
+var exportFormats = map[string]string{
+	"tar.gz": ".tar.gz",
+	"zip":    ".zip",
+	"md":     ".md",
+	"jsonl":  ".jsonl",
+	"html":   "", // a directory
+}
+
+// exportedNote is the JSON lines representation of a note
+type exportedNote struct {
+	Name     string    `json:"name"`
+	Title    string    `json:"title,omitempty"`
+	Tags     []string  `json:"tags,omitempty"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	Content  string    `json:"content"`
+}
+
+// runExportCommand handles "ks export [format] [-o output]"
+func runExportCommand(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	var format, output, tag string
+	var force bool
+	flags.StringVar(&format, "f", "", "Export format: tar.gz, zip, md, jsonl, html")
+	flags.StringVar(&format, "format", "", "Export format: tar.gz, zip, md, jsonl, html")
+	flags.StringVar(&output, "o", "", "Output file or directory (- for stdout)")
+	flags.StringVar(&output, "output", "", "Output file or directory (- for stdout)")
+	flags.StringVar(&tag, "t", "", "Only export notes with a tag")
+	flags.StringVar(&tag, "tag", "", "Only export notes with a tag")
+	flags.BoolVar(&force, "force", false, "Replace an existing output file or directory")
+	flags.Usage = func() { printExportUsage(os.Stderr) }
+
+	// Format can be given as a positional argument too: ks export zip -o notes.zip
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		format = args[0]
+		args = args[1:]
+	}
+	flags.Parse(args)
+
+	if format == "" && flags.NArg() > 0 {
+		format = flags.Arg(0)
+	}
+	// ...or inferred from the output name
+	if format == "" {
+		format = formatFromOutput(output)
+	}
+	if _, ok := exportFormats[format]; !ok {
+		usageError(printExportUsage)
+	}
+
+	if output == "" {
+		output = "ks-export-" + time.Now().Format("2006-01-02") + exportFormats[format]
+	}
+	if output == "-" && format == "html" {
+		fail(exitUsage, "The html format needs an output directory (-o site)")
+	}
+	if output == "-" && (format == "zip" || format == "tar.gz") && isTTY() {
+		fail(exitUsage, "Refusing to write a binary archive to the terminal")
+	}
+
+	notesDir := cliNotesDir()
+	notes, err := walkNotes(notesDir)
+	if err != nil {
+		failErr(err, "Could not read the notes directory")
+	}
+	if tag != "" {
+		notes = filterByTag(notes, tag)
+	}
+	notes = sortNotes(notes, "name")
+
+	if len(notes) == 0 {
+		fail(exitNotFound, "No notes to export")
+	}
+
+	err = exportNotes(notesDir, notes, format, output, force)
+	if errors.Is(err, fs.ErrExist) {
+		fail(exitExists, "'%s' already exists (use --force to replace it)", output)
+	}
+	if err != nil {
+		fail(exitIO, "Could not export notes: %v", err)
+	}
+
+	if output != "-" {
+		fmt.Println(theme.Success.Render(fmt.Sprintf("✓ Exported %d notes to %s", len(notes), output)))
+	}
+}
+
+// printExportUsage displays the export help message
+func printExportUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ks export [format] [-o output] [--tag tag] [--force]")
+	fmt.Fprintln(w, "\nFormats:")
+	fmt.Fprintln(w, "  tar.gz     Archive of the notes tree")
+	fmt.Fprintln(w, "  zip        Zip archive of the notes tree")
+	fmt.Fprintln(w, "  md         Single Markdown file with a heading per note")
+	fmt.Fprintln(w, "  jsonl      One JSON object per line with metadata and content")
+	fmt.Fprintln(w, "  html       Static HTML site (index page + one page per note)")
+	fmt.Fprintln(w, "\nThe format is guessed from -o when not given. Use -o - to write to stdout.")
+	fmt.Fprintln(w, "An existing output is only replaced with --force.")
+	fmt.Fprintln(w, "\nExamples:")
+	fmt.Fprintln(w, "  ks export zip")
+	fmt.Fprintln(w, "  ks export -o backup.tar.gz")
+	fmt.Fprintln(w, "  ks export jsonl -o - | jq .name")
+	fmt.Fprintln(w, "  ks export html -o site --tag infra")
+}
+
+// formatFromOutput guesses the export format from an output file name
+func formatFromOutput(output string) string {
+	switch {
+	case strings.HasSuffix(output, ".tar.gz"), strings.HasSuffix(output, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(output, ".zip"):
+		return "zip"
+	case strings.HasSuffix(output, ".md"):
+		return "md"
+	case strings.HasSuffix(output, ".jsonl"), strings.HasSuffix(output, ".json"):
+		return "jsonl"
+	case output != "" && output != "-" && filepath.Ext(output) == "":
+		return "html"
+	}
+	return ""
+}
+
+// exportNotes writes the given notes to output in the requested format
+// Unless force is set, an existing output is left alone and an error matching fs.ErrExist is returned
+func exportNotes(notesDir string, notes []noteInfo, format, output string, force bool) error {
+	if format == "html" {
+		if _, err := os.Lstat(output); err == nil && !force {
+			return &fs.PathError{Op: "export", Path: output, Err: fs.ErrExist}
+		}
+		return exportHTML(notesDir, notes, output)
+	}
+
+	if output == "-" {
+		return writeExport(notesDir, notes, format, os.Stdout)
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !force {
+		flags |= os.O_EXCL
+	}
+	file, err := os.OpenFile(output, flags, 0644)
+	if err != nil {
+		return err
+	}
+	err = writeExport(notesDir, notes, format, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writeExport writes the notes to w in a single-file format
+func writeExport(notesDir string, notes []noteInfo, format string, w io.Writer) error {
+	switch format {
+	case "tar.gz":
+		return exportTarGz(notesDir, notes, w)
+	case "zip":
+		return exportZip(notesDir, notes, w)
+	case "md":
+		return exportMarkdown(notesDir, notes, w)
+	case "jsonl":
+		return exportJSONLines(notesDir, notes, w)
+	}
+	return fmt.Errorf("unknown export format %q", format)
+}
+
+// exportTarGz writes the notes into a gzipped tar archive, keeping their folders
+func exportTarGz(notesDir string, notes []noteInfo, w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	for _, note := range notes {
+		content, err := os.ReadFile(notePath(notesDir, note.name))
+		if err != nil {
+			return err
+		}
+
+		header := &tar.Header{
+			Name:    note.name,
+			Mode:    0644,
+			Size:    int64(len(content)),
+			ModTime: note.modTime,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// exportZip writes the notes into a zip archive, keeping their folders
+func exportZip(notesDir string, notes []noteInfo, w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	for _, note := range notes {
+		content, err := os.ReadFile(notePath(notesDir, note.name))
+		if err != nil {
+			return err
+		}
+
+		header := &zip.FileHeader{
+			Name:     note.name,
+			Method:   zip.Deflate,
+			Modified: note.modTime,
+		}
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(content); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// exportMarkdown concatenates every note into one Markdown document with a heading per note
+func exportMarkdown(notesDir string, notes []noteInfo, w io.Writer) error {
+	fmt.Fprintf(w, "# Notes\n\nExported from ks on %s\n", time.Now().Format("2006-01-02 15:04"))
+
+	for _, note := range notes {
+		content, err := os.ReadFile(notePath(notesDir, note.name))
+		if err != nil {
+			return err
+		}
+
+		title := note.name
+		if note.meta.title != "" {
+			title = note.meta.title + " (" + note.name + ")"
+		}
+
+		fmt.Fprintf(w, "\n---\n\n## %s\n\n", title)
+		if len(note.meta.tags) > 0 {
+			fmt.Fprintf(w, "Tags: %s\n\n", formatTags(note.meta.tags))
+		}
+
+		// Drop the front matter - its title and tags are shown above
+		body := stripFrontMatter(string(content))
+		fmt.Fprintln(w, strings.TrimRight(body, "\n"))
+	}
+
+	return nil
+}
+
+// exportJSONLines writes one JSON object per note
+func exportJSONLines(notesDir string, notes []noteInfo, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+
+	for _, note := range notes {
+		content, err := os.ReadFile(notePath(notesDir, note.name))
+		if err != nil {
+			return err
+		}
+
+		err = encoder.Encode(exportedNote{
+			Name:     note.name,
+			Title:    note.meta.title,
+			Tags:     note.meta.tags,
+			Size:     note.size,
+			Modified: note.modTime,
+			Content:  string(content),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exportHTML builds a static site: index.html linking to one page per note
+func exportHTML(notesDir string, notes []noteInfo, outputDir string) error {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+
+	var index strings.Builder
+	index.WriteString("<h1>Notes</h1>\n<ul>\n")
+
+	for _, note := range notes {
+		content, err := os.ReadFile(notePath(notesDir, note.name))
+		if err != nil {
+			return err
+		}
+
+		pageName := note.name + ".html"
+		pagePath := filepath.Join(outputDir, filepath.FromSlash(pageName))
+		if err := os.MkdirAll(filepath.Dir(pagePath), 0755); err != nil {
+			return err
+		}
+
+		title := note.name
+		if note.meta.title != "" {
+			title = note.meta.title
+		}
+
+		// Link back to the index from nested folders
+		depth := strings.Count(note.name, "/")
+		home := strings.Repeat("../", depth) + "index.html"
+
+		var page strings.Builder
+		fmt.Fprintf(&page, "<p><a href=\"%s\">← All notes</a></p>\n", home)
+		fmt.Fprintf(&page, "<h1>%s</h1>\n", html.EscapeString(title))
+		if len(note.meta.tags) > 0 {
+			fmt.Fprintf(&page, "<p class=\"meta\">%s</p>\n", html.EscapeString(formatTags(note.meta.tags)))
+		}
+		fmt.Fprintf(&page, "<pre>%s</pre>\n", html.EscapeString(stripFrontMatter(string(content))))
+
+		if err := os.WriteFile(pagePath, []byte(htmlPage(title, page.String())), 0644); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(&index, "  <li><a href=\"%s\">%s</a> <span class=\"meta\">%s • %s",
+			html.EscapeString((&url.URL{Path: pageName}).String()), html.EscapeString(note.name),
+			formatSize(note.size), note.modTime.Format("2006-01-02 15:04"))
+		if len(note.meta.tags) > 0 {
+			fmt.Fprintf(&index, " • %s", html.EscapeString(formatTags(note.meta.tags)))
+		}
+		index.WriteString("</span></li>\n")
+	}
+	index.WriteString("</ul>\n")
+
+	return os.WriteFile(filepath.Join(outputDir, "index.html"), []byte(htmlPage("Notes", index.String())), 0644)
+}
+
+// htmlPage wraps body content in a minimal standalone HTML document
+func htmlPage(title, body string) string {
+	return `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>` + html.EscapeString(title) + `</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 48em; margin: 2em auto; padding: 0 1em; color: #222; }
+a { color: #8250df; }
+pre { white-space: pre-wrap; font-family: ui-monospace, monospace; }
+.meta { color: #777; font-size: 0.9em; }
+</style>
+</head>
+<body>
+` + body + `</body>
+</html>
+`
+}
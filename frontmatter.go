@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"sort"
+	"strings"
+)
+
+// noteMeta holds the metadata parsed from a note's YAML front matter
+type noteMeta struct {
+	title string
+	tags  []string
+}
+
+// hasTag reports whether the metadata includes the given tag
+func (m noteMeta) hasTag(tag string) bool {
+	tag = normalizeTag(tag)
+	for _, t := range m.tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeTag lowercases a tag and strips a leading '#' and any quotes
+func normalizeTag(tag string) string {
+	tag = strings.TrimSpace(tag)
+	tag = strings.Trim(tag, `"'`)
+	tag = strings.TrimPrefix(tag, "#")
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// readFrontMatter reads the front matter block at the top of a note file, if any
+func readFrontMatter(filePath string) noteMeta {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return noteMeta{}
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	// Front matter must start on the very first line
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "---" {
+		return noteMeta{}
+	}
+
+	var lines []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "---" || strings.TrimSpace(line) == "..." {
+			return parseFrontMatter(lines)
+		}
+		lines = append(lines, line)
+	}
+
+	// No closing delimiter - not front matter
+	return noteMeta{}
+}
+
+// frontMatterLines returns the lines of the front matter block in a note's content, if any
+func frontMatterLines(content string) []string {
+	lines := strings.Split(content, "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return nil
+	}
+
+	for i := 1; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "---" || trimmed == "..." {
+			return lines[1:i]
+		}
+	}
+
+	// No closing delimiter - not front matter
+	return nil
+}
+
+// stripFrontMatter removes a leading front matter block from note content
+func stripFrontMatter(content string) string {
+	lines := frontMatterLines(content)
+	if lines == nil {
+		return content
+	}
+
+	// Skip the opening ---, the block itself and the closing ---
+	rest := strings.SplitN(content, "\n", len(lines)+3)
+	if len(rest) < len(lines)+3 {
+		return ""
+	}
+	return strings.TrimLeft(rest[len(lines)+2], "\n")
+}
+
+// parseFrontMatter parses the simple YAML subset used in note front matter:
+//
+//	title: Weekly sync
+//	tags: [meeting, infra]
+//	tags:
+//	  - meeting
+//	  - infra
+func parseFrontMatter(lines []string) noteMeta {
+	var meta noteMeta
+	currentKey := ""
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		// List item belonging to the previous key
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			if currentKey == "tags" {
+				meta.tags = appendTags(meta.tags, strings.TrimPrefix(trimmed, "-"))
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			continue
+		}
+		currentKey = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch currentKey {
+		case "title":
+			meta.title = strings.Trim(value, `"'`)
+		case "tags", "tag":
+			currentKey = "tags"
+			value = strings.TrimPrefix(value, "[")
+			value = strings.TrimSuffix(value, "]")
+			for _, tag := range strings.Split(value, ",") {
+				meta.tags = appendTags(meta.tags, tag)
+			}
+		}
+	}
+
+	return meta
+}
+
+// appendTags adds one or more space separated tags, skipping empties and duplicates
+func appendTags(tags []string, value string) []string {
+	for _, field := range strings.Fields(value) {
+		tag := normalizeTag(field)
+		if tag == "" {
+			continue
+		}
+		duplicate := false
+		for _, existing := range tags {
+			if existing == tag {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// collectTags returns every distinct tag used by the notes, sorted
+func collectTags(notes []noteInfo) []string {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, note := range notes {
+		for _, tag := range note.meta.tags {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// filterByTag keeps only the notes carrying the given tag
+func filterByTag(notes []noteInfo, tag string) []noteInfo {
+	var filtered []noteInfo
+	for _, note := range notes {
+		if note.meta.hasTag(tag) {
+			filtered = append(filtered, note)
+		}
+	}
+	return filtered
+}
+
+// formatTags renders tags as "#a #b" for display
+func formatTags(tags []string) string {
+	formatted := make([]string, len(tags))
+	for i, tag := range tags {
+		formatted[i] = "#" + tag
+	}
+	return strings.Join(formatted, " ")
+}
+
+// setTags replaces the tags in a note's front matter, adding a front matter block
+// if the note has none. Every other front matter line is kept as it was.
+func setTags(content string, tags []string) string {
+	tagsLine := "tags: [" + strings.Join(tags, ", ") + "]"
+
+	block := frontMatterLines(content)
+	if block == nil {
+		if len(tags) == 0 {
+			return content
+		}
+		return "---\n" + tagsLine + "\n---\n" + content
+	}
+
+	var kept []string
+	inTags, replaced := false, false
+	for _, line := range block {
+		trimmed := strings.TrimSpace(line)
+		if inTags && (strings.HasPrefix(trimmed, "- ") || trimmed == "-") {
+			continue
+		}
+		inTags = false
+
+		key, _, ok := strings.Cut(trimmed, ":")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if ok && (key == "tags" || key == "tag") && line == strings.TrimLeft(line, " \t") {
+			// The tags go where the old ones were
+			if !replaced && len(tags) > 0 {
+				kept = append(kept, tagsLine)
+			}
+			inTags, replaced = true, true
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if !replaced && len(tags) > 0 {
+		kept = append(kept, tagsLine)
+	}
+
+	// Opening ---, the block, the closing line and the rest of the note
+	parts := strings.SplitN(content, "\n", len(block)+3)
+	closing, body := parts[len(block)+1], ""
+	if len(parts) == len(block)+3 {
+		body = parts[len(block)+2]
+	}
+	if len(kept) == 0 {
+		return body
+	}
+	return "---\n" + strings.Join(kept, "\n") + "\n" + closing + "\n" + body
+}
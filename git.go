@@ -0,0 +1,291 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// runGit runs git inside the notes directory and returns its trimmed output
+func runGit(notesDir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = notesDir
+	out, err := cmd.CombinedOutput()
+	output := strings.TrimSpace(string(out))
+	if err != nil {
+		if output != "" {
+			lines := strings.Split(output, "\n")
+			return output, fmt.Errorf("git %s: %s", args[0], lines[len(lines)-1])
+		}
+		return output, fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return output, nil
+}
+
+// ensureGitRepo turns the notes directory into a git repository if it isn't one yet,
+// keeps ks's own .ks folder out of it and points origin at the configured remote
+func ensureGitRepo(notesDir string) error {
+	if _, err := os.Stat(filepath.Join(notesDir, ".git")); os.IsNotExist(err) {
+		if _, err := runGit(notesDir, "init", "-q"); err != nil {
+			return err
+		}
+	}
+
+	// The index, history and trash are local state, not notes
+	ignorePath := filepath.Join(notesDir, ".gitignore")
+	ignore, err := os.ReadFile(ignorePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if !strings.Contains("\n"+string(ignore), "\n.ks/") {
+		if len(ignore) > 0 && !strings.HasSuffix(string(ignore), "\n") {
+			ignore = append(ignore, '\n')
+		}
+		ignore = append(ignore, ".ks/\n"...)
+		if err := saveFile(ignorePath, ignore); err != nil {
+			return err
+		}
+	}
+
+	if config.GitRemote != "" {
+		url, err := runGit(notesDir, "remote", "get-url", "origin")
+		if err != nil {
+			if _, err := runGit(notesDir, "remote", "add", "origin", config.GitRemote); err != nil {
+				return err
+			}
+		} else if url != config.GitRemote {
+			if _, err := runGit(notesDir, "remote", "set-url", "origin", config.GitRemote); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// gitIdentity returns -c flags for a fallback author when git has no user configured
+func gitIdentity(notesDir string) []string {
+	if email, err := runGit(notesDir, "config", "user.email"); err == nil && email != "" {
+		return nil
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	return []string{"-c", "user.name=ks", "-c", "user.email=ks@" + host}
+}
+
+// gitCommit records every change in the notes directory as one commit with the given message
+// Does nothing unless git = true in the config file, or when there is nothing to commit
+func gitCommit(notesDir, message string) error {
+	if !config.Git {
+		return nil
+	}
+	if err := ensureGitRepo(notesDir); err != nil {
+		return err
+	}
+
+	// Notes still containing conflict markers from a sync are left out, so a
+	// save elsewhere can't mark them as resolved
+	unresolved := unresolvedConflicts(notesDir)
+	addArgs := []string{"add", "-A", "--", "."}
+	for _, name := range unresolved {
+		addArgs = append(addArgs, ":(exclude)"+name)
+	}
+	if _, err := runGit(notesDir, addArgs...); err != nil {
+		return err
+	}
+	if len(unresolved) > 0 {
+		// The merge can only be committed once every conflict is resolved
+		return nil
+	}
+
+	// A new repository has no HEAD yet, so diff would fail - always commit then
+	if _, err := runGit(notesDir, "rev-parse", "--verify", "-q", "HEAD"); err == nil {
+		if _, err := runGit(notesDir, "diff", "--cached", "--quiet"); err == nil {
+			return nil
+		}
+	}
+
+	args := append(gitIdentity(notesDir), "commit", "-q", "-m", message)
+	_, err := runGit(notesDir, args...)
+	return err
+}
+
+// gitCommitCLI commits a change made from the command line, warning if it fails
+func gitCommitCLI(notesDir, message string) {
+	if err := gitCommit(notesDir, message); err != nil {
+		fmt.Fprintln(os.Stderr, theme.Warning.Render("⚠ Saved, but could not commit: "+err.Error()))
+	}
+}
+
+// checkSaved handles the result of saveNote on the command line: a failed
+// save exits with the message, a failed commit only warns
+func checkSaved(err error, format string, args ...any) {
+	if isCommitError(err) {
+		fmt.Fprintln(os.Stderr, theme.Warning.Render("⚠ Saved, but could not commit: "+errors.Unwrap(err).Error()))
+		return
+	}
+	if err != nil {
+		failErr(err, format, args...)
+	}
+}
+
+// gitConflicts returns the notes left with merge conflicts by a sync
+func gitConflicts(notesDir string) []string {
+	if !config.Git {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(notesDir, ".git")); err != nil {
+		return nil
+	}
+
+	output, err := runGit(notesDir, "diff", "--name-only", "--diff-filter=U")
+	if err != nil || output == "" {
+		return nil
+	}
+	return strings.Split(output, "\n")
+}
+
+// unresolvedConflicts returns the conflicting notes that still contain conflict markers
+func unresolvedConflicts(notesDir string) []string {
+	var unresolved []string
+	for _, name := range gitConflicts(notesDir) {
+		content, err := os.ReadFile(notePath(notesDir, name))
+		if err == nil && hasConflictMarkers(string(content)) {
+			unresolved = append(unresolved, name)
+		}
+	}
+	return unresolved
+}
+
+// hasConflictMarkers reports whether text contains a <<<<<<< conflict marker line
+func hasConflictMarkers(text string) bool {
+	return strings.HasPrefix(text, "<<<<<<< ") || strings.Contains(text, "\n<<<<<<< ")
+}
+
+// syncNotes commits local changes, merges the remote's changes and pushes
+// Conflicting notes are returned (with conflict markers left in them) instead of being overwritten
+func syncNotes(notesDir string) (string, []string, error) {
+	if err := ensureGitRepo(notesDir); err != nil {
+		return "", nil, err
+	}
+	if conflicts := gitConflicts(notesDir); len(conflicts) > 0 {
+		return "", conflicts, fmt.Errorf("resolve the conflicts from the last sync first")
+	}
+	if err := gitCommit(notesDir, "Sync local changes"); err != nil {
+		return "", nil, err
+	}
+
+	if _, err := runGit(notesDir, "remote", "get-url", "origin"); err != nil {
+		return "", nil, fmt.Errorf("no remote configured - set git_remote in the config file")
+	}
+	branch, err := runGit(notesDir, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", nil, err
+	}
+
+	if _, err := runGit(notesDir, "fetch", "-q", "origin"); err != nil {
+		return "", nil, err
+	}
+
+	summary := "Pushed to origin/" + branch
+	if _, err := runGit(notesDir, "rev-parse", "--verify", "-q", "origin/"+branch); err == nil {
+		before, _ := runGit(notesDir, "rev-parse", "HEAD")
+		args := append(gitIdentity(notesDir), "merge", "--no-edit", "--allow-unrelated-histories", "origin/"+branch)
+		if _, err := runGit(notesDir, args...); err != nil {
+			if conflicts := gitConflicts(notesDir); len(conflicts) > 0 {
+				return "", conflicts, fmt.Errorf("merge conflicts with origin/%s", branch)
+			}
+			return "", nil, err
+		}
+		after, _ := runGit(notesDir, "rev-parse", "HEAD")
+		if before != after {
+			summary = "Pulled changes and pushed to origin/" + branch
+		}
+	}
+
+	if _, err := runGit(notesDir, "push", "-q", "-u", "origin", branch); err != nil {
+		return "", nil, err
+	}
+	return summary, nil, nil
+}
+
+// runSyncCommand handles "ks sync"
+func runSyncCommand(args []string) {
+	if len(args) > 0 {
+		usageError(printSyncUsage)
+	}
+	if !config.Git {
+		fail(exitFailure, "Git is not enabled - set git = true in the config file")
+	}
+	notesDir := cliNotesDir()
+
+	summary, conflicts, err := syncNotes(notesDir)
+	if err != nil {
+		if len(conflicts) > 0 {
+			fmt.Fprintln(os.Stderr, theme.Warning.Render("Conflicting notes (look for <<<<<<< markers, then save):"))
+			for _, name := range conflicts {
+				fmt.Fprintln(os.Stderr, "  "+name)
+			}
+			fmt.Fprintln(os.Stderr)
+		}
+		fail(exitFailure, "Sync failed: %v", err)
+	}
+	fmt.Println(theme.Success.Render("✓ " + summary))
+}
+
+// runGitCommand handles "ks git log [note]" and passes anything else straight to git
+func runGitCommand(args []string) {
+	if len(args) == 0 {
+		usageError(printGitUsage)
+	}
+	notesDir := cliNotesDir()
+
+	if args[0] == "log" && len(args) <= 2 {
+		logArgs := []string{"log", "--date=format:%Y-%m-%d %H:%M", "--format=%h  %ad  %s"}
+		if len(args) == 2 {
+			checkName(args[1])
+			logArgs = append(logArgs, "--follow", "--", args[1])
+		}
+		output, err := runGit(notesDir, logArgs...)
+		if err != nil {
+			fail(exitFailure, "%v", err)
+		}
+		if output == "" {
+			fmt.Println("No commits yet.")
+			return
+		}
+		fmt.Println(output)
+		return
+	}
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = notesDir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			os.Exit(exitErr.ExitCode())
+		}
+		fail(exitFailure, "%v", err)
+	}
+}
+
+// printSyncUsage shows the help for "ks sync"
+func printSyncUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ks sync")
+	fmt.Fprintln(w, "\nCommits local changes, pulls from the git remote and pushes back.")
+	fmt.Fprintln(w, "Needs git = true (and git_remote) in the config file.")
+}
+
+// printGitUsage shows the help for "ks git"
+func printGitUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ks git log [note]")
+	fmt.Fprintln(w, "       ks git <git arguments...>   Run git in the notes directory")
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// revisionIDFormat is the layout of snapshot names (millisecond timestamps sort in order)
+const revisionIDFormat = "20060102-150405.000"
+
+// revision is a saved version of a note, stored in .ks/history/<note name>/<id>
+type revision struct {
+	id   string
+	time time.Time
+	size int64
+}
+
+// historyDir returns the folder holding the snapshots of a note
+func historyDir(notesDir, name string) string {
+	return filepath.Join(notesDir, ".ks", "history", filepath.FromSlash(name))
+}
+
+// path returns the snapshot file of a revision
+func (r revision) path(notesDir, name string) string {
+	return filepath.Join(historyDir(notesDir, name), r.id)
+}
+
+// listRevisions returns the saved versions of a note, newest first
+func listRevisions(notesDir, name string) ([]revision, error) {
+	entries, err := os.ReadDir(historyDir(notesDir, name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var revisions []revision
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if len(entry.Name()) < len(revisionIDFormat) {
+			continue
+		}
+		t, err := time.ParseInLocation(revisionIDFormat, entry.Name()[:len(revisionIDFormat)], time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		revisions = append(revisions, revision{id: entry.Name(), time: t, size: info.Size()})
+	}
+
+	sort.SliceStable(revisions, func(i, j int) bool {
+		if !revisions[i].time.Equal(revisions[j].time) {
+			return revisions[i].time.After(revisions[j].time)
+		}
+		// Same millisecond: "-10" is newer than "-2"
+		if len(revisions[i].id) != len(revisions[j].id) {
+			return len(revisions[i].id) > len(revisions[j].id)
+		}
+		return revisions[i].id > revisions[j].id
+	})
+
+	return revisions, nil
+}
+
+// snapshotNote records the note's current content as a new revision, unless it
+// is unchanged since the last one. Called before and after every save so that
+// content written before history existed (or by other programs) is kept too.
+// Errors are ignored: history must never get in the way of saving.
+func snapshotNote(notesDir, name string) {
+	content, err := os.ReadFile(notePath(notesDir, name))
+	if err != nil {
+		return
+	}
+
+	revisions, _ := listRevisions(notesDir, name)
+	if len(revisions) > 0 {
+		latest, err := os.ReadFile(revisions[0].path(notesDir, name))
+		if err == nil && bytes.Equal(latest, content) {
+			return
+		}
+	}
+
+	dir := historyDir(notesDir, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+
+	base := time.Now().Format(revisionIDFormat)
+	id := base
+	for i := 2; ; i++ {
+		file, err := os.OpenFile(filepath.Join(dir, id), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			id = fmt.Sprintf("%s-%d", base, i)
+			continue
+		}
+		if err != nil {
+			return
+		}
+		_, err = file.Write(content)
+		if closeErr := file.Close(); err != nil || closeErr != nil {
+			os.Remove(filepath.Join(dir, id))
+		}
+		return
+	}
+}
+
+// moveHistory keeps a note's history with it when the note is renamed
+func moveHistory(notesDir, oldName, newName string) {
+	oldDir := historyDir(notesDir, oldName)
+	if _, err := os.Stat(oldDir); err != nil {
+		return
+	}
+	newDir := historyDir(notesDir, newName)
+	if err := os.MkdirAll(filepath.Dir(newDir), 0755); err != nil {
+		return
+	}
+	os.RemoveAll(newDir)
+	os.Rename(oldDir, newDir)
+}
+
+// findRevision looks a revision up by its number in "ks history" (1 = newest),
+// its id, or a unique prefix of the id
+func findRevision(revisions []revision, ref string) (revision, int, error) {
+	if n, err := strconv.Atoi(strings.TrimPrefix(ref, "#")); err == nil && n >= 1 && n <= len(revisions) && len(ref) <= 4 {
+		return revisions[n-1], n, nil
+	}
+
+	found := -1
+	for i, r := range revisions {
+		if r.id == ref {
+			return r, i + 1, nil
+		}
+		if strings.HasPrefix(r.id, ref) {
+			if found >= 0 {
+				return revision{}, 0, fmt.Errorf("'%s' matches more than one version", ref)
+			}
+			found = i
+		}
+	}
+	if found < 0 {
+		return revision{}, 0, fmt.Errorf("no version '%s'", ref)
+	}
+	return revisions[found], found + 1, nil
+}
+
+// historyLines describes each revision with its change against the one before it
+func historyLines(notesDir, name string, revisions []revision) []string {
+	current, _ := os.ReadFile(notePath(notesDir, name))
+
+	var lines []string
+	var older []byte
+	for i := len(revisions) - 1; i >= 0; i-- {
+		r := revisions[i]
+		content, _ := os.ReadFile(r.path(notesDir, name))
+
+		change := "created"
+		if i < len(revisions)-1 {
+			added, removed := diffStats(string(older), string(content))
+			change = fmt.Sprintf("+%d -%d", added, removed)
+		}
+		older = content
+
+		line := fmt.Sprintf("#%-3d %s  %8s  %s", i+1, r.time.Format("2006-01-02 15:04:05"), formatSize(r.size), change)
+		if i == 0 && bytes.Equal(content, current) {
+			line += "  (current)"
+		}
+		lines = append([]string{line}, lines...)
+	}
+	return lines
+}
+
+// previousRevision returns the newest revision that differs from the note's current content
+func previousRevision(notesDir, name string, revisions []revision) (revision, int, bool) {
+	current, err := os.ReadFile(notePath(notesDir, name))
+	for i, r := range revisions {
+		content, readErr := os.ReadFile(r.path(notesDir, name))
+		if readErr == nil && (err != nil || !bytes.Equal(content, current)) {
+			return r, i + 1, true
+		}
+	}
+	return revision{}, 0, false
+}
+
+// historyPanel renders the history of a note for the list view's preview panel
+func historyPanel(notesDir, name string) string {
+	revisions, err := listRevisions(notesDir, name)
+	if err != nil {
+		return theme.Error.Render("Error reading history")
+	}
+	if len(revisions) == 0 {
+		return theme.Muted.Render("No history yet - versions are saved every time the note is written")
+	}
+
+	var panel strings.Builder
+	for _, line := range historyLines(notesDir, name, revisions) {
+		panel.WriteString(line + "\n")
+	}
+
+	// Show what the last save changed
+	if r, n, ok := previousRevision(notesDir, name, revisions); ok {
+		old, _ := os.ReadFile(r.path(notesDir, name))
+		current, _ := os.ReadFile(notePath(notesDir, name))
+		panel.WriteString("\n" + theme.Secondary.Render(fmt.Sprintf("Changes since #%d:", n)) + "\n")
+		panel.WriteString(colorDiff(unifiedDiff(name+"@"+r.id, name, string(old), string(current))))
+	}
+
+	panel.WriteString("\n" + theme.Muted.Render("ks diff / ks restore "+name+" <#>"))
+	return panel.String()
+}
+
+// runHistoryCommand handles "ks history <note>"
+func runHistoryCommand(args []string) {
+	if len(args) != 1 {
+		usageError(printHistoryUsage)
+	}
+	name := args[0]
+
+	notesDir, revisions := openHistory(name)
+	if len(revisions) == 0 {
+		fmt.Printf("No history for '%s'.\n", name)
+		return
+	}
+
+	for _, line := range historyLines(notesDir, name, revisions) {
+		fmt.Println(line)
+	}
+}
+
+// runDiffCommand handles "ks diff <note> [rev]"
+func runDiffCommand(args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		usageError(printDiffUsage)
+	}
+	name := args[0]
+
+	notesDir, revisions := openHistory(name)
+
+	var r revision
+	var n int
+	if len(args) == 2 {
+		var err error
+		r, n, err = findRevision(revisions, args[1])
+		if err != nil {
+			fail(exitNotFound, "%v (see 'ks history %s')", err, name)
+		}
+	} else {
+		var ok bool
+		r, n, ok = previousRevision(notesDir, name, revisions)
+		if !ok {
+			fmt.Printf("No earlier version of '%s'.\n", name)
+			return
+		}
+	}
+
+	old, err := os.ReadFile(r.path(notesDir, name))
+	if err != nil {
+		failErr(err, "Could not read version #%d", n)
+	}
+	current, _ := os.ReadFile(notePath(notesDir, name))
+
+	diff := unifiedDiff(fmt.Sprintf("%s (#%d %s)", name, n, r.time.Format("2006-01-02 15:04:05")), name, string(old), string(current))
+	if diff == "" {
+		fmt.Printf("Version #%d is the same as the current note.\n", n)
+		return
+	}
+	if isTTY() {
+		diff = colorDiff(diff)
+	}
+	fmt.Print(diff)
+}
+
+// runRestoreCommand handles "ks restore <note> <rev>"
+func runRestoreCommand(args []string) {
+	if len(args) != 2 {
+		usageError(printRestoreUsage)
+	}
+	name := args[0]
+
+	notesDir, revisions := openHistory(name)
+	r, n, err := findRevision(revisions, args[1])
+	if err != nil {
+		fail(exitNotFound, "%v (see 'ks history %s')", err, name)
+	}
+
+	content, err := os.ReadFile(r.path(notesDir, name))
+	if err != nil {
+		failErr(err, "Could not read version #%d", n)
+	}
+
+	checkSaved(saveNote(notesDir, name, content, fmt.Sprintf("Restore %s to version #%d", name, n)), "Could not write '%s'", name)
+
+	fmt.Println(theme.Success.Render(fmt.Sprintf("✓ Restored %s to version #%d (%s)", name, n, r.time.Format("2006-01-02 15:04:05"))))
+}
+
+// openHistory validates a note name and loads its revisions (CLI helper, exits on error)
+func openHistory(name string) (string, []revision) {
+	checkName(name)
+	notesDir := cliNotesDir()
+
+	revisions, err := listRevisions(notesDir, name)
+	if err != nil {
+		failErr(err, "Could not read the history of '%s'", name)
+	}
+	return notesDir, revisions
+}
+
+// printHistoryUsage shows the help for "ks history"
+func printHistoryUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ks history <note>")
+	fmt.Fprintln(w, "\nLists the saved versions of a note, newest first. Use the # numbers with")
+	fmt.Fprintln(w, "'ks diff <note> <#>' and 'ks restore <note> <#>'.")
+}
+
+// printDiffUsage shows the help for "ks diff"
+func printDiffUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ks diff <note> [#]")
+	fmt.Fprintln(w, "\nShows what changed between a saved version (default: the previous one)")
+	fmt.Fprintln(w, "and the note as it is now.")
+}
+
+// printRestoreUsage shows the help for "ks restore"
+func printRestoreUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ks restore <note> <#>")
+	fmt.Fprintln(w, "\nRolls a note back to a saved version. The current content is kept in the")
+	fmt.Fprintln(w, "history, so a restore can be undone.")
+}
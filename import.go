@@ -0,0 +1,778 @@
+package main
+
+import (
+	"archive/tar"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// importedNote is a note read from another tool, ready to be written into the notes directory
+type importedNote struct {
+	name    string // proposed note name (may still need fixing)
+	content string
+	modTime time.Time // zero if unknown
+}
+
+// runImportCommand handles "ks import <path> [--from format] [--into folder] [--dry-run]"
+func runImportCommand(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	var from, into string
+	var dryRun bool
+	fs.StringVar(&from, "from", "", "Source format: obsidian, joplin, evernote (guessed if omitted)")
+	fs.StringVar(&into, "into", "", "Folder to import notes into")
+	fs.BoolVar(&dryRun, "dry-run", false, "Show what would be imported without writing anything")
+	fs.Usage = func() { printImportUsage(os.Stderr) }
+
+	// Allow the path before the flags: ks import vault --into work
+	var source string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		source = args[0]
+		args = args[1:]
+	}
+	fs.Parse(args)
+	if source == "" && fs.NArg() > 0 {
+		source = fs.Arg(0)
+	}
+	if source == "" {
+		usageError(printImportUsage)
+	}
+
+	if into != "" {
+		into = strings.Trim(into, "/")
+		if err := validateFilename(into); err != nil {
+			fail(exitInvalid, "Invalid folder '%s': %v", into, err)
+		}
+	}
+
+	if from == "" {
+		from = detectImportFormat(source)
+	}
+
+	var notes []importedNote
+	var err error
+	switch from {
+	case "obsidian", "markdown":
+		notes, err = readObsidianVault(source)
+	case "joplin":
+		notes, err = readJoplinExport(source)
+	case "evernote", "enex":
+		notes, err = readEvernoteExport(source)
+	default:
+		fail(exitUsage, "Can't tell what kind of export '%s' is - use --from obsidian|joplin|evernote", source)
+	}
+	if err != nil {
+		failErr(err, "Could not read %s", source)
+	}
+
+	notesDir := cliNotesDir()
+
+	imported, skipped := importNotes(notesDir, notes, into, dryRun)
+	if imported > 0 && !dryRun {
+		gitCommitCLI(notesDir, fmt.Sprintf("Import %d notes from %s", imported, filepath.Base(source)))
+	}
+
+	summary := fmt.Sprintf("✓ Imported %d notes from %s", imported, source)
+	if dryRun {
+		summary = fmt.Sprintf("Would import %d notes from %s (dry run)", imported, source)
+	}
+	fmt.Println(theme.Success.Render(summary))
+	if skipped > 0 {
+		fmt.Fprintln(os.Stderr, theme.Warning.Render(fmt.Sprintf("⚠ Skipped %d notes (see above)", skipped)))
+		os.Exit(exitFailure)
+	}
+}
+
+// printImportUsage displays the import help message
+func printImportUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ks import <path> [--from format] [--into folder] [--dry-run]")
+	fmt.Fprintln(w, "\nSources:")
+	fmt.Fprintln(w, "  obsidian   Vault directory (folders and front matter are kept)")
+	fmt.Fprintln(w, "  joplin     RAW export directory or .jex file")
+	fmt.Fprintln(w, "  evernote   .enex file (notes are converted to Markdown)")
+	fmt.Fprintln(w, "\nExisting notes are never overwritten: collisions are reported and skipped.")
+	fmt.Fprintln(w, "\nExamples:")
+	fmt.Fprintln(w, "  ks import ~/Documents/Vault")
+	fmt.Fprintln(w, "  ks import notes.jex --into joplin")
+	fmt.Fprintln(w, "  ks import export.enex --dry-run")
+}
+
+// detectImportFormat guesses the source format from a path
+func detectImportFormat(source string) string {
+	lower := strings.ToLower(source)
+	switch {
+	case strings.HasSuffix(lower, ".enex"):
+		return "evernote"
+	case strings.HasSuffix(lower, ".jex"):
+		return "joplin"
+	}
+
+	info, err := os.Stat(source)
+	if err != nil || !info.IsDir() {
+		return ""
+	}
+
+	// Obsidian vaults have a .obsidian settings folder
+	if _, err := os.Stat(filepath.Join(source, ".obsidian")); err == nil {
+		return "obsidian"
+	}
+
+	// Joplin RAW exports are flat folders of <32 hex id>.md items
+	entries, err := os.ReadDir(source)
+	if err == nil {
+		for _, entry := range entries {
+			if joplinItemName.MatchString(entry.Name()) {
+				return "joplin"
+			}
+		}
+	}
+
+	// Any other folder of Markdown files is imported like a vault
+	return "obsidian"
+}
+
+// importNotes writes imported notes without overwriting anything
+// Returns the number of notes imported and the number skipped
+func importNotes(notesDir string, notes []importedNote, into string, dryRun bool) (int, int) {
+	imported, skipped := 0, 0
+	taken := make(map[string]bool)
+	var written []string
+
+	for _, note := range notes {
+		name := note.name
+		if into != "" {
+			name = into + "/" + name
+		}
+
+		// Run every name through the same checks as interactive writes
+		if err := validateFilename(name); err != nil {
+			suggested := suggestFilename(name)
+			if suggested == "" || validateFilename(suggested) != nil {
+				printError("%s: %v", name, err)
+				skipped++
+				continue
+			}
+			fmt.Println(theme.Muted.Render(fmt.Sprintf("  %s → %s", name, suggested)))
+			name = suggested
+		}
+
+		filePath := notePath(notesDir, name)
+		if _, err := os.Lstat(filePath); err == nil || taken[name] {
+			fmt.Fprintln(os.Stderr, theme.Warning.Render(fmt.Sprintf("⚠ %s: already exists, skipped", name)))
+			skipped++
+			continue
+		}
+		taken[name] = true
+
+		if dryRun {
+			fmt.Println(theme.Muted.Render("  " + name))
+			imported++
+			continue
+		}
+
+		if err := createNote(filePath, note.content); err != nil {
+			if errors.Is(err, fs.ErrExist) {
+				fmt.Fprintln(os.Stderr, theme.Warning.Render(fmt.Sprintf("⚠ %s: already exists, skipped", name)))
+			} else {
+				printError("%s: %v", name, err)
+			}
+			skipped++
+			continue
+		}
+		if !note.modTime.IsZero() {
+			os.Chtimes(filePath, note.modTime, note.modTime)
+		}
+
+		written = append(written, name)
+		imported++
+	}
+
+	if len(written) > 0 {
+		updateIndex(notesDir, written...)
+	}
+
+	return imported, skipped
+}
+
+// createNote writes a new note, failing if the file already exists
+func createNote(filePath, content string) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+
+	return saveNewFile(filePath, []byte(content))
+}
+
+// titleToFilename turns a note title into a safe Markdown filename
+func titleToFilename(title string) string {
+	name := strings.TrimSpace(title)
+	name = strings.NewReplacer("/", "-", "\\", "-", ":", "-").Replace(name)
+	name = strings.TrimLeft(name, ".")
+	if name == "" {
+		name = "Untitled"
+	}
+	return name + ".md"
+}
+
+// buildFrontMatter renders a front matter block for imported metadata
+func buildFrontMatter(title string, tags []string, created time.Time) string {
+	var fm strings.Builder
+	fm.WriteString("---\n")
+	if title != "" {
+		fm.WriteString("title: " + strconv.Quote(title) + "\n")
+	}
+	if len(tags) > 0 {
+		fm.WriteString("tags: [" + strings.Join(tags, ", ") + "]\n")
+	}
+	if !created.IsZero() {
+		fm.WriteString("created: " + created.Format(time.RFC3339) + "\n")
+	}
+	fm.WriteString("---\n")
+	return fm.String()
+}
+
+// readObsidianVault collects the Markdown notes of a vault, keeping its folder structure
+// Front matter is already in ks's format, so files are copied as they are
+func readObsidianVault(root string) ([]importedNote, error) {
+	var notes []importedNote
+	attachments := 0
+
+	err := filepath.WalkDir(root, func(filePath string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if filePath == root {
+			return nil
+		}
+
+		// Skip .obsidian, .trash and other hidden files
+		if strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if ext != ".md" && ext != ".markdown" && ext != ".txt" {
+			attachments++
+			return nil
+		}
+
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+
+		notes = append(notes, importedNote{
+			name:    filepath.ToSlash(rel),
+			content: string(content),
+			modTime: info.ModTime(),
+		})
+		return nil
+	})
+
+	if attachments > 0 {
+		fmt.Println(theme.Muted.Render(fmt.Sprintf("  (%d attachments skipped - only notes are imported)", attachments)))
+	}
+
+	return notes, err
+}
+
+// joplinItemName matches the file names of items in a Joplin RAW export
+var joplinItemName = regexp.MustCompile(`^[0-9a-f]{32}\.md$`)
+
+// joplinMetaLine matches a "key: value" metadata line at the end of a Joplin item
+var joplinMetaLine = regexp.MustCompile(`^[a-z_]+: ?.*$`)
+
+// Joplin item types (the type_ metadata field)
+const (
+	joplinNote    = "1"
+	joplinFolder  = "2"
+	joplinTag     = "5"
+	joplinNoteTag = "6"
+)
+
+// joplinItem is one entry (note, notebook, tag...) of a Joplin export
+type joplinItem struct {
+	title string
+	body  string
+	meta  map[string]string
+}
+
+// readJoplinExport reads a Joplin RAW export directory or a .jex archive
+func readJoplinExport(source string) ([]importedNote, error) {
+	items := make(map[string]joplinItem)
+
+	addItem := func(data string) {
+		item := parseJoplinItem(data)
+		if id := item.meta["id"]; id != "" {
+			items[id] = item
+		}
+	}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		entries, err := os.ReadDir(source)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !joplinItemName.MatchString(entry.Name()) {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(source, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			addItem(string(data))
+		}
+	} else {
+		// A .jex file is a tar archive of RAW items (plus resources)
+		file, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		tr := tar.NewReader(file)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if !joplinItemName.MatchString(path.Base(header.Name)) {
+				continue
+			}
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			addItem(string(data))
+		}
+	}
+
+	// Oldest first (ties by id), so imports and name collisions are the same every run
+	ids := make([]string, 0, len(items))
+	for id := range items {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := parseJoplinTime(items[ids[i]].meta["created_time"]), parseJoplinTime(items[ids[j]].meta["created_time"])
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return ids[i] < ids[j]
+	})
+
+	// Tags are linked to notes through note_tag items
+	noteTags := make(map[string][]string)
+	for _, id := range ids {
+		item := items[id]
+		if item.meta["type_"] != joplinNoteTag {
+			continue
+		}
+		if tag, ok := items[item.meta["tag_id"]]; ok {
+			noteID := item.meta["note_id"]
+			noteTags[noteID] = appendTags(noteTags[noteID], strings.ReplaceAll(tag.title, " ", "-"))
+		}
+	}
+
+	var notes []importedNote
+	for _, id := range ids {
+		item := items[id]
+		if item.meta["type_"] != joplinNote {
+			continue
+		}
+
+		// Rebuild the notebook path from the parent chain
+		var folders []string
+		parent := item.meta["parent_id"]
+		for depth := 0; parent != "" && depth < 32; depth++ {
+			folder, ok := items[parent]
+			if !ok || folder.meta["type_"] != joplinFolder {
+				break
+			}
+			folders = append([]string{strings.TrimSuffix(titleToFilename(folder.title), ".md")}, folders...)
+			parent = folder.meta["parent_id"]
+		}
+
+		created := parseJoplinTime(item.meta["created_time"])
+		content := buildFrontMatter(item.title, noteTags[id], created) + item.body
+		if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+
+		notes = append(notes, importedNote{
+			name:    path.Join(append(folders, titleToFilename(item.title))...),
+			content: content,
+			modTime: parseJoplinTime(item.meta["updated_time"]),
+		})
+	}
+
+	return notes, nil
+}
+
+// parseJoplinItem splits a RAW item into its title, body and trailing metadata block
+func parseJoplinItem(data string) joplinItem {
+	lines := strings.Split(strings.TrimRight(strings.ReplaceAll(data, "\r\n", "\n"), "\n"), "\n")
+
+	// Metadata is the run of "key: value" lines at the end
+	metaStart := len(lines)
+	for metaStart > 0 && joplinMetaLine.MatchString(lines[metaStart-1]) {
+		metaStart--
+	}
+
+	item := joplinItem{meta: make(map[string]string)}
+	for _, line := range lines[metaStart:] {
+		key, value, _ := strings.Cut(line, ":")
+		item.meta[key] = strings.TrimSpace(value)
+	}
+
+	content := lines[:metaStart]
+	if len(content) > 0 {
+		item.title = strings.TrimSpace(content[0])
+	}
+	if len(content) > 2 {
+		item.body = strings.TrimSpace(strings.Join(content[2:], "\n"))
+	}
+
+	return item
+}
+
+// parseJoplinTime parses Joplin's ISO 8601 timestamps, returning zero on failure
+func parseJoplinTime(value string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// enexNote is a <note> element of an Evernote export
+type enexNote struct {
+	Title   string   `xml:"title"`
+	Content string   `xml:"content"`
+	Created string   `xml:"created"`
+	Updated string   `xml:"updated"`
+	Tags    []string `xml:"tag"`
+}
+
+// readEvernoteExport reads the notes of an .enex file, converting ENML to Markdown
+func readEvernoteExport(source string) ([]importedNote, error) {
+	file, err := os.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	decoder := xml.NewDecoder(file)
+	var notes []importedNote
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "note" {
+			continue
+		}
+
+		var note enexNote
+		if err := decoder.DecodeElement(&note, &start); err != nil {
+			return nil, err
+		}
+
+		var tags []string
+		for _, tag := range note.Tags {
+			tags = appendTags(tags, strings.ReplaceAll(tag, " ", "-"))
+		}
+
+		body, err := enmlToMarkdown(note.Content)
+		if err != nil {
+			return nil, fmt.Errorf("note '%s': %v", note.Title, err)
+		}
+
+		notes = append(notes, importedNote{
+			name:    titleToFilename(note.Title),
+			content: buildFrontMatter(note.Title, tags, parseEnexTime(note.Created)) + body,
+			modTime: parseEnexTime(note.Updated),
+		})
+	}
+
+	return notes, nil
+}
+
+// parseEnexTime parses Evernote's compact timestamps (20240131T154500Z)
+func parseEnexTime(value string) time.Time {
+	t, err := time.Parse("20060102T150405Z", strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// enmlConverter turns ENML (Evernote's XHTML dialect) into Markdown
+type enmlConverter struct {
+	buffers []*strings.Builder // nested output for blockquotes; the last one is current
+	lists   []enmlList
+	links   []string // hrefs of the open <a> elements
+	pre     int      // depth of <pre> elements
+}
+
+// enmlList tracks an open <ul> or <ol>
+type enmlList struct {
+	ordered bool
+	count   int
+}
+
+// enmlToMarkdown converts the content of an Evernote note to Markdown
+func enmlToMarkdown(enml string) (string, error) {
+	decoder := xml.NewDecoder(strings.NewReader(enml))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	c := &enmlConverter{buffers: []*strings.Builder{{}}}
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			c.start(t)
+		case xml.EndElement:
+			c.end(t.Name.Local)
+		case xml.CharData:
+			c.text(string(t))
+		}
+	}
+
+	result := strings.TrimSpace(c.buffers[0].String())
+	// Collapse runs of blank lines left over from nested blocks
+	for strings.Contains(result, "\n\n\n") {
+		result = strings.ReplaceAll(result, "\n\n\n", "\n\n")
+	}
+	return result + "\n", nil
+}
+
+// out returns the buffer currently being written
+func (c *enmlConverter) out() *strings.Builder {
+	return c.buffers[len(c.buffers)-1]
+}
+
+// newline makes sure the output ends with a line break
+func (c *enmlConverter) newline() {
+	s := c.out().String()
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		c.out().WriteString("\n")
+	}
+}
+
+// blankLine makes sure the output ends with an empty line (a block boundary)
+func (c *enmlConverter) blankLine() {
+	s := c.out().String()
+	if s == "" || strings.HasSuffix(s, "\n\n") {
+		return
+	}
+	c.newline()
+	c.out().WriteString("\n")
+}
+
+func (c *enmlConverter) start(t xml.StartElement) {
+	attr := func(name string) string {
+		for _, a := range t.Attr {
+			if a.Name.Local == name {
+				return a.Value
+			}
+		}
+		return ""
+	}
+
+	switch t.Name.Local {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		c.blankLine()
+		level := int(t.Name.Local[1] - '0')
+		c.out().WriteString(strings.Repeat("#", level) + " ")
+	case "p", "div", "table":
+		if len(c.lists) == 0 {
+			c.blankLine()
+		} else {
+			c.newline()
+		}
+	case "tr":
+		c.newline()
+		c.out().WriteString("|")
+	case "td", "th":
+		c.out().WriteString(" ")
+	case "br":
+		c.out().WriteString("\n")
+	case "hr":
+		c.blankLine()
+		c.out().WriteString("---\n\n")
+	case "b", "strong":
+		c.out().WriteString("**")
+	case "i", "em":
+		c.out().WriteString("*")
+	case "s", "strike", "del":
+		c.out().WriteString("~~")
+	case "code":
+		if c.pre == 0 {
+			c.out().WriteString("`")
+		}
+	case "pre":
+		c.blankLine()
+		c.out().WriteString("```\n")
+		c.pre++
+	case "a":
+		c.links = append(c.links, attr("href"))
+		c.out().WriteString("[")
+	case "ul", "ol":
+		if len(c.lists) == 0 {
+			c.blankLine()
+		}
+		c.lists = append(c.lists, enmlList{ordered: t.Name.Local == "ol"})
+	case "li":
+		c.newline()
+		indent := strings.Repeat("  ", max(len(c.lists)-1, 0))
+		marker := "- "
+		if len(c.lists) > 0 && c.lists[len(c.lists)-1].ordered {
+			c.lists[len(c.lists)-1].count++
+			marker = strconv.Itoa(c.lists[len(c.lists)-1].count) + ". "
+		}
+		c.out().WriteString(indent + marker)
+	case "en-todo":
+		if attr("checked") == "true" {
+			c.out().WriteString("[x] ")
+		} else {
+			c.out().WriteString("[ ] ")
+		}
+	case "en-media":
+		c.out().WriteString("[attachment: " + attr("type") + "]")
+	case "blockquote":
+		c.blankLine()
+		c.buffers = append(c.buffers, &strings.Builder{})
+	}
+}
+
+func (c *enmlConverter) end(name string) {
+	switch name {
+	case "h1", "h2", "h3", "h4", "h5", "h6", "p", "div", "table":
+		if len(c.lists) == 0 {
+			c.blankLine()
+		} else {
+			c.newline()
+		}
+	case "td", "th":
+		c.out().WriteString(" |")
+	case "tr":
+		c.newline()
+	case "b", "strong":
+		c.out().WriteString("**")
+	case "i", "em":
+		c.out().WriteString("*")
+	case "s", "strike", "del":
+		c.out().WriteString("~~")
+	case "code":
+		if c.pre == 0 {
+			c.out().WriteString("`")
+		}
+	case "pre":
+		c.newline()
+		c.out().WriteString("```\n\n")
+		c.pre = max(c.pre-1, 0)
+	case "a":
+		href := ""
+		if len(c.links) > 0 {
+			href = c.links[len(c.links)-1]
+			c.links = c.links[:len(c.links)-1]
+		}
+		c.out().WriteString("](" + href + ")")
+	case "ul", "ol":
+		if len(c.lists) > 0 {
+			c.lists = c.lists[:len(c.lists)-1]
+		}
+		if len(c.lists) == 0 {
+			c.blankLine()
+		}
+	case "li":
+		c.newline()
+	case "blockquote":
+		if len(c.buffers) < 2 {
+			return
+		}
+		quoted := strings.TrimSpace(c.out().String())
+		c.buffers = c.buffers[:len(c.buffers)-1]
+		for _, line := range strings.Split(quoted, "\n") {
+			c.out().WriteString(strings.TrimRight("> "+line, " ") + "\n")
+		}
+		c.out().WriteString("\n")
+	}
+}
+
+func (c *enmlConverter) text(s string) {
+	if c.pre > 0 {
+		c.out().WriteString(s)
+		return
+	}
+
+	// Collapse whitespace like a browser would
+	collapsed := strings.Join(strings.Fields(s), " ")
+	if collapsed == "" {
+		if s != "" && !strings.HasSuffix(c.out().String(), " ") && !strings.HasSuffix(c.out().String(), "\n") && c.out().Len() > 0 {
+			c.out().WriteString(" ")
+		}
+		return
+	}
+
+	current := c.out().String()
+	atLineStart := current == "" || strings.HasSuffix(current, "\n") || strings.HasSuffix(current, " ")
+	if strings.TrimLeftFunc(s, unicode.IsSpace) != s && !atLineStart {
+		c.out().WriteString(" ")
+	}
+	c.out().WriteString(collapsed)
+	if strings.TrimRightFunc(s, unicode.IsSpace) != s {
+		c.out().WriteString(" ")
+	}
+}
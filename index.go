@@ -0,0 +1,561 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// indexVersion is bumped whenever the on-disk index format changes
+const indexVersion = 2
+
+// maxIndexLog is the size past which the index log is merged into index.json
+const maxIndexLog = 1 << 20
+
+// BM25 ranking parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// searchIndex is a persistent inverted index of every note's words,
+// stored as JSON in the .ks folder of the notes directory
+type searchIndex struct {
+	Version  int                       `json:"version"`
+	Docs     map[string]*indexDoc      `json:"docs"`     // note name → document
+	Postings map[string]map[string]int `json:"postings"` // term → note name → term frequency
+
+	path  string
+	terms []string // the terms of Postings, sorted for prefix lookups (built when needed)
+}
+
+// indexDoc records what the index knows about one note
+type indexDoc struct {
+	ModTime int64    `json:"mod_time"` // unix nanoseconds, to detect changes
+	Size    int64    `json:"size"`
+	Length  int      `json:"length"` // number of terms, for BM25 length normalization
+	Terms   []string `json:"terms"`  // distinct terms, to remove the document again
+	Title   string   `json:"title,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	Links   []string `json:"links,omitempty"` // [[link]] targets as written, to find backlinks
+}
+
+// indexChange is one line of the index log, where single-note updates are
+// appended instead of rewriting index.json: a note indexed with its document
+// and term frequencies, or (without a document) a note or folder dropped
+type indexChange struct {
+	Name        string         `json:"name"`
+	Doc         *indexDoc      `json:"doc,omitempty"`
+	Frequencies map[string]int `json:"frequencies,omitempty"`
+}
+
+// rankedResult is a search result with its relevance score
+type rankedResult struct {
+	searchResult
+	score float64
+}
+
+// loadIndex reads the search index, returning an empty one if it is missing or unreadable
+func loadIndex(notesDir string) *searchIndex {
+	idx := &searchIndex{
+		Version:  indexVersion,
+		Docs:     make(map[string]*indexDoc),
+		Postings: make(map[string]map[string]int),
+		path:     indexPath(notesDir),
+	}
+
+	data, err := os.ReadFile(idx.path)
+	if err != nil {
+		return idx
+	}
+
+	var loaded searchIndex
+	if err := json.Unmarshal(data, &loaded); err != nil || loaded.Version != indexVersion {
+		// Corrupt or outdated - start over, refresh will rebuild it
+		return idx
+	}
+	if loaded.Docs != nil {
+		idx.Docs = loaded.Docs
+	}
+	if loaded.Postings != nil {
+		idx.Postings = loaded.Postings
+	}
+
+	// Replay the changes logged since it was saved; a line cut short by a
+	// crash ends the replay, and refresh catches up with the notes on disk
+	if file, err := os.Open(indexLogPath(notesDir)); err == nil {
+		decoder := json.NewDecoder(file)
+		for {
+			var change indexChange
+			if decoder.Decode(&change) != nil {
+				break
+			}
+			idx.apply(change)
+		}
+		file.Close()
+	}
+
+	return idx
+}
+
+// indexPath returns the location of the search index
+func indexPath(notesDir string) string {
+	return filepath.Join(notesDir, ".ks", "index.json")
+}
+
+// indexLogPath returns the location of the index log
+func indexLogPath(notesDir string) string {
+	return filepath.Join(notesDir, ".ks", "index.log")
+}
+
+// save writes the index back to disk, replacing the old file atomically,
+// and clears the log it now includes
+func (idx *searchIndex) save() error {
+	if err := os.MkdirAll(filepath.Dir(idx.path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+
+	if err := saveFile(idx.path, data); err != nil {
+		return err
+	}
+	os.Remove(filepath.Join(filepath.Dir(idx.path), "index.log"))
+	return nil
+}
+
+// appendIndexLog records changes in the index log, merging the log into
+// index.json once it has grown past maxIndexLog
+func appendIndexLog(notesDir string, changes []indexChange) {
+	var lines bytes.Buffer
+	encoder := json.NewEncoder(&lines)
+	for _, change := range changes {
+		if err := encoder.Encode(change); err != nil {
+			return
+		}
+	}
+
+	logPath := indexLogPath(notesDir)
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		return
+	}
+	file, err := os.OpenFile(logPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return
+	}
+	_, err = file.Write(lines.Bytes())
+	file.Close()
+
+	if info, statErr := os.Stat(logPath); err == nil && statErr == nil && info.Size() > maxIndexLog {
+		loadIndex(notesDir).save()
+	}
+}
+
+// tokenize splits text into lowercase words (runs of letters and digits)
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// newIndexDoc builds the index document and term frequencies of a note's content
+func newIndexDoc(note noteInfo, content string) (*indexDoc, map[string]int) {
+	// Encrypted notes are found by name only
+	if isEncrypted([]byte(content)) {
+		content = ""
+	}
+
+	frequencies := make(map[string]int)
+	terms := tokenize(content)
+	for _, term := range terms {
+		frequencies[term]++
+	}
+
+	doc := &indexDoc{
+		ModTime: note.modTime.UnixNano(),
+		Size:    note.size,
+		Length:  len(terms),
+		Title:   note.meta.title,
+		Tags:    note.meta.tags,
+		Links:   linkTargets(content),
+	}
+	for term := range frequencies {
+		doc.Terms = append(doc.Terms, term)
+	}
+	sort.Strings(doc.Terms)
+	return doc, frequencies
+}
+
+// add indexes (or re-indexes) a note's content
+func (idx *searchIndex) add(note noteInfo, content string) {
+	doc, frequencies := newIndexDoc(note, content)
+	idx.insert(note.name, doc, frequencies)
+}
+
+// insert puts a note's document and term frequencies into the index
+func (idx *searchIndex) insert(name string, doc *indexDoc, frequencies map[string]int) {
+	idx.remove(name)
+
+	for term, count := range frequencies {
+		postings, ok := idx.Postings[term]
+		if !ok {
+			postings = make(map[string]int)
+			idx.Postings[term] = postings
+			idx.terms = nil
+		}
+		postings[name] = count
+	}
+	idx.Docs[name] = doc
+}
+
+// apply makes a logged change to the index
+func (idx *searchIndex) apply(change indexChange) {
+	if change.Doc != nil {
+		idx.insert(change.Name, change.Doc, change.Frequencies)
+		return
+	}
+	// Drop the note itself and anything that was inside it (for folders)
+	for docName := range idx.Docs {
+		if docName == change.Name || strings.HasPrefix(docName, change.Name+"/") {
+			idx.remove(docName)
+		}
+	}
+}
+
+// remove drops a note from the index
+func (idx *searchIndex) remove(name string) {
+	doc, ok := idx.Docs[name]
+	if !ok {
+		return
+	}
+
+	for _, term := range doc.Terms {
+		delete(idx.Postings[term], name)
+		if len(idx.Postings[term]) == 0 {
+			delete(idx.Postings, term)
+			idx.terms = nil
+		}
+	}
+	delete(idx.Docs, name)
+}
+
+// indexFile reads a note from disk and adds it to the index
+func (idx *searchIndex) indexFile(notesDir string, note noteInfo) {
+	idx.apply(readIndexChange(notesDir, note))
+}
+
+// readIndexChange reads a note from disk and returns the change indexing it
+// (dropping it if it can't be read)
+func readIndexChange(notesDir string, note noteInfo) indexChange {
+	content, err := os.ReadFile(notePath(notesDir, note.name))
+	if err != nil {
+		return indexChange{Name: note.name}
+	}
+	note.meta = parseFrontMatter(frontMatterLines(string(content)))
+	doc, frequencies := newIndexDoc(note, string(content))
+	return indexChange{Name: note.name, Doc: doc, Frequencies: frequencies}
+}
+
+// refresh brings the index up to date with the notes on disk, re-reading only
+// notes whose size or modification time changed. Returns true if anything changed.
+func (idx *searchIndex) refresh(notesDir string, notes []noteInfo) bool {
+	changed := false
+	present := make(map[string]bool, len(notes))
+
+	for _, note := range notes {
+		present[note.name] = true
+		doc, ok := idx.Docs[note.name]
+		if ok && doc.ModTime == note.modTime.UnixNano() && doc.Size == note.size {
+			continue
+		}
+		idx.indexFile(notesDir, note)
+		changed = true
+	}
+
+	for name := range idx.Docs {
+		if !present[name] {
+			idx.remove(name)
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// meta returns the front matter recorded for an indexed note
+func (idx *searchIndex) meta(name string) noteMeta {
+	if doc, ok := idx.Docs[name]; ok {
+		return noteMeta{title: doc.Title, tags: doc.Tags}
+	}
+	return noteMeta{}
+}
+
+// candidates returns the notes that could match every included term of a query,
+// using the index instead of reading files. Returns nil, false if the query
+// can't be narrowed down this way (regex mode or no included terms).
+func (idx *searchIndex) candidates(query searchQuery) (map[string]bool, bool) {
+	if query.regex || len(query.include) == 0 {
+		return nil, false
+	}
+
+	var result map[string]bool
+	for _, term := range query.include {
+		matches := make(map[string]bool)
+
+		// The term can match the filename...
+		termLower := strings.ToLower(term.text)
+		for name := range idx.Docs {
+			if strings.Contains(strings.ToLower(name), termLower) {
+				matches[name] = true
+			}
+		}
+
+		// ...or the content: every word of the term must start some indexed word
+		tokens := tokenize(term.text)
+		if len(tokens) == 0 {
+			// Only punctuation - the index can't help
+			return nil, false
+		}
+		var contentMatches map[string]bool
+		for _, token := range tokens {
+			docs := idx.docsMatching(token)
+			if contentMatches == nil {
+				contentMatches = docs
+				continue
+			}
+			for name := range contentMatches {
+				if !docs[name] {
+					delete(contentMatches, name)
+				}
+			}
+		}
+		for name := range contentMatches {
+			matches[name] = true
+		}
+
+		if result == nil {
+			result = matches
+			continue
+		}
+		for name := range result {
+			if !matches[name] {
+				delete(result, name)
+			}
+		}
+	}
+
+	return result, true
+}
+
+// matchingTerms returns the indexed words starting with token, found by
+// binary search in the sorted list of terms
+func (idx *searchIndex) matchingTerms(token string) []string {
+	if idx.terms == nil {
+		idx.terms = make([]string, 0, len(idx.Postings))
+		for term := range idx.Postings {
+			idx.terms = append(idx.terms, term)
+		}
+		sort.Strings(idx.terms)
+	}
+
+	start := sort.SearchStrings(idx.terms, token)
+	end := start
+	for end < len(idx.terms) && strings.HasPrefix(idx.terms[end], token) {
+		end++
+	}
+	return idx.terms[start:end]
+}
+
+// docsMatching returns the notes with an indexed word starting with token
+func (idx *searchIndex) docsMatching(token string) map[string]bool {
+	docs := make(map[string]bool)
+	for _, term := range idx.matchingTerms(token) {
+		for name := range idx.Postings[term] {
+			docs[name] = true
+		}
+	}
+	return docs
+}
+
+// scorer prepares BM25 ranking of notes against the query's included terms
+// Words count when an indexed word starts with them, and filename hits get a small boost
+func (idx *searchIndex) scorer(query searchQuery) func(name string) float64 {
+	totalLength := 0
+	for _, doc := range idx.Docs {
+		totalLength += doc.Length
+	}
+	n := float64(len(idx.Docs))
+	avgLength := math.Max(float64(totalLength)/math.Max(n, 1), 1)
+
+	// Term frequency per note and inverse document frequency for every query word
+	type tokenStats struct {
+		tf  map[string]int
+		idf float64
+	}
+	var stats []tokenStats
+	for _, term := range query.include {
+		for _, token := range tokenize(term.text) {
+			tf := make(map[string]int)
+			for _, word := range idx.matchingTerms(token) {
+				for name, count := range idx.Postings[word] {
+					tf[name] += count
+				}
+			}
+			df := float64(len(tf))
+			stats = append(stats, tokenStats{tf: tf, idf: math.Log(1 + (n-df+0.5)/(df+0.5))})
+		}
+	}
+
+	return func(name string) float64 {
+		score := 0.0
+		for _, term := range query.include {
+			if strings.Contains(strings.ToLower(name), strings.ToLower(term.text)) {
+				score++
+			}
+		}
+
+		doc, ok := idx.Docs[name]
+		if !ok {
+			return score
+		}
+		for _, stat := range stats {
+			tf := float64(stat.tf[name])
+			if tf == 0 {
+				continue
+			}
+			norm := tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(doc.Length)/avgLength))
+			score += stat.idf * norm
+		}
+		return score
+	}
+}
+
+// indexedSearch runs a query with the help of the index and returns results ranked by relevance
+// Contents of the matching notes are returned for snippets
+func indexedSearch(notesDir string, query searchQuery) ([]rankedResult, map[string]string, error) {
+	notes, err := walkNoteFiles(notesDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	idx := loadIndex(notesDir)
+	if idx.refresh(notesDir, notes) {
+		idx.save()
+	}
+
+	candidates, narrowed := idx.candidates(query)
+	score := idx.scorer(query)
+
+	var results []rankedResult
+	contents := make(map[string]string)
+	for _, note := range notes {
+		if narrowed && !candidates[note.name] {
+			continue
+		}
+
+		// Tags come from the index; check them before reading the file
+		note.meta = idx.meta(note.name)
+		if !query.matchesTags(note) {
+			continue
+		}
+
+		// Queries on names and tags only are answered without reading the notes
+		var content []byte
+		if query.needsContent() {
+			content, err = os.ReadFile(notePath(notesDir, note.name))
+			if err != nil {
+				continue // deleted since the walk, or unreadable
+			}
+			if isEncrypted(content) {
+				content = nil
+			}
+		}
+		ok, matchLocation := query.match(note, string(content))
+		if !ok {
+			continue
+		}
+
+		results = append(results, rankedResult{
+			searchResult: searchResult{note: note, matchLocation: matchLocation},
+			score:        score(note.name),
+		})
+		contents[note.name] = string(content)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		return results[i].note.name < results[j].note.name
+	})
+
+	return results, contents, nil
+}
+
+// updateIndex re-indexes the named notes (or folders) after they were written, renamed or deleted
+// The changes are appended to the index log, so index.json isn't read or rewritten.
+// Errors are ignored: the index is only a cache and is refreshed on the next search
+func updateIndex(notesDir string, names ...string) {
+	var changes []indexChange
+	for _, name := range names {
+		// Drop the note itself and anything that was inside it (for folders)
+		changes = append(changes, indexChange{Name: name})
+
+		info, err := os.Stat(notePath(notesDir, name))
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			changes = append(changes, readIndexChange(notesDir, noteInfo{name: name, modTime: info.ModTime(), size: info.Size()}))
+			continue
+		}
+
+		notes, err := walkNoteFiles(notePath(notesDir, name))
+		if err != nil {
+			continue
+		}
+		for _, note := range notes {
+			note.name = name + "/" + note.name
+			changes = append(changes, readIndexChange(notesDir, note))
+		}
+	}
+
+	if len(changes) > 0 {
+		appendIndexLog(notesDir, changes)
+	}
+}
+
+// reindexNotes rebuilds the search index from scratch
+func reindexNotes() {
+	notesDir := cliNotesDir()
+
+	start := time.Now()
+	notes, err := walkNoteFiles(notesDir)
+	if err != nil {
+		failErr(err, "Could not read the notes directory")
+	}
+
+	idx := loadIndex(notesDir)
+	idx.Docs = make(map[string]*indexDoc)
+	idx.Postings = make(map[string]map[string]int)
+	for _, note := range notes {
+		idx.indexFile(notesDir, note)
+	}
+
+	if err := idx.save(); err != nil {
+		fail(exitIO, "Could not write the index: %v", err)
+	}
+
+	fmt.Println(theme.Success.Render(fmt.Sprintf("✓ Indexed %d notes (%d words) in %s",
+		len(idx.Docs), len(idx.Postings), time.Since(start).Round(time.Millisecond))))
+}
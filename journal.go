@@ -0,0 +1,293 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// journalDirectives maps the strftime-style directives of journal_format to Go layouts
+var journalDirectives = map[byte]string{
+	'Y': "2006",
+	'y': "06",
+	'm': "01",
+	'd': "02",
+	'e': "_2",
+	'j': "002",
+	'a': "Mon",
+	'A': "Monday",
+	'b': "Jan",
+	'B': "January",
+	'%': "%",
+}
+
+// journalLayout converts a journal_format pattern ("%Y-%m-%d") to a Go time layout
+// A pattern without any % directive is taken as a Go layout already.
+func journalLayout(pattern string) string {
+	if !strings.Contains(pattern, "%") {
+		return pattern
+	}
+	var layout strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '%' && i+1 < len(pattern) {
+			if directive, ok := journalDirectives[pattern[i+1]]; ok {
+				layout.WriteString(directive)
+				i++
+				continue
+			}
+		}
+		layout.WriteByte(pattern[i])
+	}
+	return layout.String()
+}
+
+// journalNoteName returns the name of the journal note for a day, e.g. "journal/2026-10-18.md"
+func journalNoteName(day time.Time) string {
+	name := day.Format(journalLayout(config.JournalFormat))
+	if path.Ext(name) == "" {
+		name += ".md"
+	}
+	return path.Join(config.JournalDir, name)
+}
+
+// journalDay returns the day a journal note is for, if the name matches the pattern
+func journalDay(name string) (time.Time, bool) {
+	rel := name
+	if config.JournalDir != "" {
+		var ok bool
+		if rel, ok = strings.CutPrefix(name, config.JournalDir+"/"); !ok {
+			return time.Time{}, false
+		}
+	}
+	layout := journalLayout(config.JournalFormat)
+	if path.Ext(layout) == "" {
+		rel = strings.TrimSuffix(rel, path.Ext(rel))
+	}
+	day, err := time.ParseInLocation(layout, rel, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return day, true
+}
+
+// dayKey identifies a calendar day
+func dayKey(day time.Time) string {
+	return day.Format("2006-01-02")
+}
+
+// journalEntries returns the days that have a journal note
+func journalEntries(notesDir string) map[string]bool {
+	entries := make(map[string]bool)
+	notes, err := walkNoteFiles(notesDir)
+	if err != nil {
+		return entries
+	}
+	for _, note := range notes {
+		if day, ok := journalDay(note.name); ok {
+			entries[dayKey(day)] = true
+		}
+	}
+	return entries
+}
+
+// offsetPattern matches day offsets such as -1 (yesterday) or +7 (a week from now)
+var offsetPattern = regexp.MustCompile(`^[+-]\d+$`)
+
+// parseJournalDate reads the day given to "ks journal": YYYY-MM-DD, today,
+// yesterday, tomorrow or an offset in days like -1
+func parseJournalDate(arg string, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	switch strings.ToLower(arg) {
+	case "", "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	}
+	if offsetPattern.MatchString(arg) {
+		days, err := strconv.Atoi(arg)
+		if err == nil {
+			return today.AddDate(0, 0, days), nil
+		}
+	}
+	day, err := time.ParseInLocation("2006-01-02", arg, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q (use YYYY-MM-DD, today, yesterday, tomorrow or -N)", arg)
+	}
+	return day, nil
+}
+
+// journalContent returns the text a new journal note starts with: the
+// journal_template with its variables filled in for the day, or a heading with the date
+func journalContent(notesDir string, day time.Time) (string, error) {
+	title := day.Format("Monday, January 2, 2006")
+	if config.JournalTemplate == "" {
+		return "# " + title + "\n\n", nil
+	}
+
+	template, err := readTemplate(notesDir, config.JournalTemplate)
+	if err != nil {
+		return "", fmt.Errorf("reading journal template: %v", err)
+	}
+	vars := templateVars(journalNoteName(day), time.Now())
+	vars["date"] = day.Format("2006-01-02")
+	vars["title"] = title
+	return expandTemplate(template, vars), nil
+}
+
+// openJournalNote returns the journal note for a day, creating it if it doesn't exist yet
+// Reports whether the note was created.
+func openJournalNote(notesDir string, day time.Time) (string, bool, error) {
+	name := journalNoteName(day)
+	if err := validateFilename(name); err != nil {
+		return "", false, fmt.Errorf("journal_dir and journal_format give an invalid note name: %v", err)
+	}
+	filePath := notePath(notesDir, name)
+	if _, err := os.Stat(filePath); err == nil {
+		return name, false, nil
+	}
+
+	content, err := journalContent(notesDir, day)
+	if err != nil {
+		return "", false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return "", false, err
+	}
+	if err := saveNewFile(filePath, []byte(content)); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return name, false, nil
+		}
+		return "", false, err
+	}
+	snapshotNote(notesDir, name)
+	updateIndex(notesDir, name)
+	return name, true, nil
+}
+
+// runJournalCommand handles "ks today" and "ks journal [date]"
+func runJournalCommand(command string, args []string) {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	var entry string
+	flags.StringVar(&entry, "a", "", "Add a timestamped bullet instead of opening the note")
+	flags.StringVar(&entry, "append", "", "Add a timestamped bullet instead of opening the note")
+	flags.Usage = func() { printJournalUsage(os.Stderr) }
+
+	// The date comes first, and may look like a flag: ks journal -1 -a "text"
+	var date string
+	if command == "journal" && len(args) > 0 && (!strings.HasPrefix(args[0], "-") || offsetPattern.MatchString(args[0])) {
+		date = args[0]
+		args = args[1:]
+	}
+	flags.Parse(args)
+	rest := flags.Args()
+	if command == "journal" && date == "" && len(rest) > 0 {
+		date, rest = rest[0], rest[1:]
+	}
+	if len(rest) > 0 {
+		usageError(printJournalUsage)
+	}
+
+	now := time.Now()
+	day, err := parseJournalDate(date, now)
+	if err != nil {
+		fail(exitUsage, "%v", err)
+	}
+	notesDir := cliNotesDir()
+
+	name, created, err := openJournalNote(notesDir, day)
+	if err != nil {
+		fail(exitIO, "Could not create the journal note: %v", err)
+	}
+	if created {
+		gitCommitCLI(notesDir, "Create "+name)
+	}
+
+	// -a adds a bullet stamped with the current time
+	if entry != "" {
+		appendNote(name, "- "+now.Format("15:04")+" "+entry+"\n")
+		return
+	}
+
+	// Without a terminal, print the note's path (e.g. for $EDITOR "$(ks today)")
+	if !isTTY() {
+		fmt.Println(notePath(notesDir, name))
+		return
+	}
+	editJournalNote(notesDir, name)
+}
+
+// editJournalNote opens a journal note in $VISUAL/$EDITOR or the built-in editor
+// Encrypted notes are unlocked and stay in the built-in editor, so no plaintext
+// is written to disk
+func editJournalNote(notesDir, name string) {
+	filePath := notePath(notesDir, name)
+	content, version, err := readFileVersion(filePath)
+	if err != nil {
+		failErr(err, "Could not read '%s'", name)
+	}
+	passphrase := ""
+	if isEncrypted(content) {
+		passphrase, content = askPassphrase("Unlock "+name, content)
+		unlockedNotes[name] = passphrase
+	}
+
+	if config.ExternalEditor && passphrase == "" {
+		snapshotNote(notesDir, name)
+		changed, err := runExternalEditor(filePath)
+		if err == nil {
+			if changed {
+				edited, err := os.ReadFile(filePath)
+				if err == nil {
+					err = saveNote(notesDir, name, edited, "Edit "+name)
+				}
+				checkSaved(err, "Could not save '%s'", name)
+				fmt.Println(theme.Success.Render("✓ Saved changes to " + name))
+			}
+			return
+		}
+		fmt.Fprintln(os.Stderr, theme.Warning.Render("⚠ "+err.Error()+" - using the built-in editor"))
+		// The editor may have touched the file before failing
+		if content, version, err = readFileVersion(filePath); err != nil {
+			failErr(err, "Could not read '%s'", name)
+		}
+	}
+
+	app := newAppModel(editorScreen)
+	app.editor = newNoteEditorModel(name, string(content))
+	app.editor.passphrase = passphrase
+	app.editor.watchFile(filePath, version)
+	app = runApp(app, tea.WithAltScreen())
+
+	if app.saved {
+		fmt.Println(theme.Success.Render("✓ Saved changes to " + name))
+	}
+}
+
+// printJournalUsage shows the help for "ks today" and "ks journal"
+func printJournalUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ks today [-a text]")
+	fmt.Fprintln(w, "   or: ks journal [date] [-a text]")
+	fmt.Fprintln(w, "\nOpens the journal note for a day, creating it (from journal_template if set)")
+	fmt.Fprintln(w, "when it doesn't exist yet. The date is YYYY-MM-DD, today, yesterday, tomorrow")
+	fmt.Fprintln(w, "or an offset in days such as -1.")
+	fmt.Fprintln(w, "\nFlags:")
+	fmt.Fprintln(w, "  -a, --append <text>   Add \"- HH:MM text\" to the note instead of opening it")
+	fmt.Fprintln(w, "\nExamples:")
+	fmt.Fprintln(w, "  ks today")
+	fmt.Fprintln(w, "  ks today -a \"shipped the importer\"")
+	fmt.Fprintln(w, "  ks journal yesterday")
+	fmt.Fprintln(w, "  ks journal 2026-03-02")
+}
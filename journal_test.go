@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJournalLayout(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"%Y-%m-%d", "2006-01-02"},
+		{"%Y/%m/%d", "2006/01/02"},
+		{"%y%m%d %a", "060102 Mon"},
+		{"%A, %B %e", "Monday, January _2"},
+		{"%Y-%j.txt", "2006-002.txt"},
+		{"100%% %Y", "100% 2006"},
+		{"%q%Y", "%q2006"},
+		{"%Y-%", "2006-%"},
+		{"2006-01-02", "2006-01-02"}, // already a Go layout
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := journalLayout(tt.pattern); got != tt.want {
+			t.Errorf("journalLayout(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestParseJournalDate(t *testing.T) {
+	now := time.Date(2026, time.March, 1, 15, 4, 5, 0, time.Local)
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		arg     string
+		want    time.Time
+		wantErr bool
+	}{
+		{arg: "", want: day(2026, time.March, 1)},
+		{arg: "today", want: day(2026, time.March, 1)},
+		{arg: "Today", want: day(2026, time.March, 1)},
+		{arg: "yesterday", want: day(2026, time.February, 28)},
+		{arg: "tomorrow", want: day(2026, time.March, 2)},
+		{arg: "-1", want: day(2026, time.February, 28)},
+		{arg: "+7", want: day(2026, time.March, 8)},
+		{arg: "-365", want: day(2025, time.March, 1)},
+		{arg: "+0", want: day(2026, time.March, 1)},
+		{arg: "2024-02-29", want: day(2024, time.February, 29)},
+		{arg: "2025-02-29", wantErr: true},
+		{arg: "2026-3-2", wantErr: true},
+		{arg: "03/02/2026", wantErr: true},
+		{arg: "next week", wantErr: true},
+		{arg: "+", wantErr: true},
+		{arg: "7", wantErr: true}, // offsets need a sign
+	}
+
+	for _, tt := range tests {
+		got, err := parseJournalDate(tt.arg, now)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseJournalDate(%q) = %v, want an error", tt.arg, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseJournalDate(%q): %v", tt.arg, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseJournalDate(%q) = %v, want %v", tt.arg, got, tt.want)
+		}
+	}
+}
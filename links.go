@@ -0,0 +1,257 @@
+package main
+
+import (
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// wikiLinkPattern matches [[note]], [[note#heading]] and [[note|label]]
+var wikiLinkPattern = regexp.MustCompile(`\[\[([^\[\]|#\n]+)(#[^\[\]|\n]*)?(\|[^\[\]\n]*)?\]\]`)
+
+// wikiLink is a [[link]] found in a note
+type wikiLink struct {
+	target string // note name as written, e.g. "standup" or "work/standup.md"
+	label  string // text shown instead of the target ("" = the target)
+	start  int    // byte offsets of the whole [[...]] in the text
+	end    int
+}
+
+// parseLinks returns the [[links]] in text, in order
+func parseLinks(text string) []wikiLink {
+	var links []wikiLink
+	for _, match := range wikiLinkPattern.FindAllStringSubmatchIndex(text, -1) {
+		link := wikiLink{
+			target: strings.TrimSpace(text[match[2]:match[3]]),
+			start:  match[0],
+			end:    match[1],
+		}
+		if match[6] >= 0 {
+			link.label = strings.TrimSpace(text[match[6]+1 : match[7]])
+		}
+		if link.target != "" {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// linkTargets returns the distinct link targets in text, in order
+func linkTargets(text string) []string {
+	var targets []string
+	seen := make(map[string]bool)
+	for _, link := range parseLinks(text) {
+		if !seen[link.target] {
+			seen[link.target] = true
+			targets = append(targets, link.target)
+		}
+	}
+	return targets
+}
+
+// linkAt returns the link at a byte offset in a line of text
+func linkAt(line string, offset int) (wikiLink, bool) {
+	for _, link := range parseLinks(line) {
+		if offset >= link.start && offset <= link.end {
+			return link, true
+		}
+	}
+	return wikiLink{}, false
+}
+
+// resolveLink finds the note a link points to among the given note names
+// A link names a note by its path ("work/standup.md"), its path without the .md
+// extension ("work/standup"), or just its file name when that is unambiguous
+// enough ("standup" - the shortest path wins). Matching ignores case.
+func resolveLink(target string, names []string) (string, bool) {
+	target = strings.TrimPrefix(strings.TrimSpace(target), "/")
+	if target == "" {
+		return "", false
+	}
+
+	// Exact path, with or without the extension
+	for _, name := range names {
+		if strings.EqualFold(name, target) || strings.EqualFold(strings.TrimSuffix(name, path.Ext(name)), target) {
+			return name, true
+		}
+	}
+	if strings.Contains(target, "/") {
+		return "", false
+	}
+
+	// Just the file name, anywhere in the notes directory
+	var matches []string
+	for _, name := range names {
+		base := path.Base(name)
+		if strings.EqualFold(base, target) || strings.EqualFold(strings.TrimSuffix(base, path.Ext(base)), target) {
+			matches = append(matches, name)
+		}
+	}
+	if len(matches) == 0 {
+		return "", false
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if strings.Count(matches[i], "/") != strings.Count(matches[j], "/") {
+			return strings.Count(matches[i], "/") < strings.Count(matches[j], "/")
+		}
+		return matches[i] < matches[j]
+	})
+	return matches[0], true
+}
+
+// linkIndex loads the search index, which records every note's links, brought up to date
+func linkIndex(notesDir string) *searchIndex {
+	idx := loadIndex(notesDir)
+	if notes, err := walkNoteFiles(notesDir); err == nil && idx.refresh(notesDir, notes) {
+		idx.save()
+	}
+	return idx
+}
+
+// names returns the names of every indexed note
+func (idx *searchIndex) names() []string {
+	names := make([]string, 0, len(idx.Docs))
+	for name := range idx.Docs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// backlinks maps each note to the notes linking to it, sorted by name
+func (idx *searchIndex) backlinks() map[string][]string {
+	names := idx.names()
+	backlinks := make(map[string][]string)
+	for _, name := range names {
+		seen := make(map[string]bool)
+		for _, target := range idx.Docs[name].Links {
+			resolved, ok := resolveLink(target, names)
+			if !ok || resolved == name || seen[resolved] {
+				continue
+			}
+			seen[resolved] = true
+			backlinks[resolved] = append(backlinks[resolved], name)
+		}
+	}
+	return backlinks
+}
+
+// findNote resolves a link against the notes on disk
+func findNote(notesDir, target string) (string, bool) {
+	return resolveLink(target, linkIndex(notesDir).names())
+}
+
+// linkUpdate is a note whose links must be rewritten after a rename
+type linkUpdate struct {
+	name    string            // the linking note, under its name before the rename
+	targets map[string]string // link target -> the note it found before the rename
+}
+
+// renamedNote returns the name a note has after oldName is renamed to newName:
+// newName for oldName itself, and the same path under newName for the notes of
+// a renamed folder
+func renamedNote(name, oldName, newName string) (string, bool) {
+	if name == oldName {
+		return newName, true
+	}
+	if rest, ok := strings.CutPrefix(name, oldName+"/"); ok {
+		return newName + "/" + rest, true
+	}
+	return name, false
+}
+
+// linksTo finds the notes linking to oldName, or to a note in it when it is a
+// folder, and the link targets they use
+// It must run before the rename, while the links still resolve to the old names.
+func linksTo(notesDir, oldName string) []linkUpdate {
+	idx := linkIndex(notesDir)
+	names := idx.names()
+
+	var updates []linkUpdate
+	for _, name := range names {
+		targets := make(map[string]string)
+		for _, target := range idx.Docs[name].Links {
+			resolved, ok := resolveLink(target, names)
+			if ok && (resolved == oldName || strings.HasPrefix(resolved, oldName+"/")) {
+				targets[target] = resolved
+			}
+		}
+		if len(targets) > 0 {
+			updates = append(updates, linkUpdate{name: name, targets: targets})
+		}
+	}
+	return updates
+}
+
+// linkError is returned after a rename when the links in some notes could not
+// be updated; the rename itself succeeded
+type linkError struct {
+	names []string
+}
+
+func (e *linkError) Error() string {
+	return "could not update links in " + strings.Join(e.names, ", ")
+}
+
+// updateLinks rewrites the links found by linksTo so they point to newName (or
+// to the new paths of a renamed folder's notes), keeping each link's style (file
+// name or path, with or without .md) where that still finds the note. Returns
+// the names of the notes changed, and a *linkError for those that couldn't be.
+func updateLinks(notesDir, oldName, newName string, updates []linkUpdate) ([]string, error) {
+	names := linkIndex(notesDir).names()
+
+	var changed, failed []string
+	for _, update := range updates {
+		// The linking note may have been renamed along with the others
+		name, _ := renamedNote(update.name, oldName, newName)
+		content, err := os.ReadFile(notePath(notesDir, name))
+		if err != nil {
+			failed = append(failed, name)
+			continue
+		}
+
+		rewritten := wikiLinkPattern.ReplaceAllStringFunc(string(content), func(match string) string {
+			loc := wikiLinkPattern.FindStringSubmatchIndex(match)
+			oldTarget := strings.TrimSpace(match[loc[2]:loc[3]])
+			resolved, ok := update.targets[oldTarget]
+			if !ok {
+				return match
+			}
+			newNote, _ := renamedNote(resolved, oldName, newName)
+			// Keep the #heading and |label
+			return match[:loc[2]] + newLinkTarget(oldTarget, newNote, names) + match[loc[3]:]
+		})
+		if rewritten == string(content) {
+			continue
+		}
+
+		if err := saveNote(notesDir, name, []byte(rewritten), ""); err != nil {
+			failed = append(failed, name)
+			continue
+		}
+		changed = append(changed, name)
+	}
+	if len(failed) > 0 {
+		return changed, &linkError{failed}
+	}
+	return changed, nil
+}
+
+// newLinkTarget writes a link to newName in the style of the old target
+func newLinkTarget(oldTarget, newName string, names []string) string {
+	target := newName
+	if !strings.Contains(oldTarget, "/") {
+		target = path.Base(newName)
+	}
+	if path.Ext(oldTarget) == "" && strings.EqualFold(path.Ext(target), ".md") {
+		target = strings.TrimSuffix(target, path.Ext(target))
+	}
+
+	// A bare file name may now find a different note - use the full path
+	if resolved, ok := resolveLink(target, names); !ok || resolved != newName {
+		target = newName
+	}
+	return target
+}
@@ -0,0 +1,112 @@
+package main
+
+import "testing"
+
+func TestResolveLink(t *testing.T) {
+	names := []string{
+		"inbox.md",
+		"standup.md",
+		"work/standup.md",
+		"work/plan.md",
+		"work/projects/plan.txt",
+		"archive/old/plan.md",
+		"README",
+	}
+
+	tests := []struct {
+		target string
+		want   string
+		ok     bool
+	}{
+		{"inbox.md", "inbox.md", true},
+		{"inbox", "inbox.md", true},
+		{"INBOX", "inbox.md", true},
+		{" inbox ", "inbox.md", true},
+		{"/work/plan", "work/plan.md", true},
+		{"work/standup.md", "work/standup.md", true},
+		{"work/Standup", "work/standup.md", true},
+		{"work/projects/plan.txt", "work/projects/plan.txt", true},
+		{"README", "README", true},
+
+		// A bare file name finds the note with the shortest path
+		{"standup", "standup.md", true},
+		{"plan", "work/plan.md", true},
+		{"plan.txt", "work/projects/plan.txt", true},
+
+		// A path has to match in full
+		{"projects/plan", "", false},
+		{"old/plan.md", "", false},
+
+		{"missing", "", false},
+		{"", "", false},
+		{"/", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := resolveLink(tt.target, names)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("resolveLink(%q) = %q, %v; want %q, %v", tt.target, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNewLinkTarget(t *testing.T) {
+	tests := []struct {
+		name      string
+		oldTarget string
+		newName   string
+		names     []string
+		want      string
+	}{
+		{
+			name:      "bare name stays bare",
+			oldTarget: "standup", newName: "daily.md",
+			names: []string{"daily.md", "inbox.md"},
+			want:  "daily",
+		},
+		{
+			name:      "extension is kept",
+			oldTarget: "standup.md", newName: "daily.md",
+			names: []string{"daily.md"},
+			want:  "daily.md",
+		},
+		{
+			name:      "bare name into a folder",
+			oldTarget: "standup", newName: "work/daily.md",
+			names: []string{"work/daily.md", "inbox.md"},
+			want:  "daily",
+		},
+		{
+			name:      "path stays a path",
+			oldTarget: "work/standup", newName: "team/daily.md",
+			names: []string{"team/daily.md"},
+			want:  "team/daily",
+		},
+		{
+			name:      "path with extension",
+			oldTarget: "work/standup.md", newName: "daily.md",
+			names: []string{"daily.md"},
+			want:  "daily.md",
+		},
+		{
+			name:      "other extensions are kept",
+			oldTarget: "script", newName: "bin/deploy.sh",
+			names: []string{"bin/deploy.sh"},
+			want:  "deploy.sh",
+		},
+		{
+			name:      "ambiguous bare name uses the full path",
+			oldTarget: "standup", newName: "work/daily.md",
+			names: []string{"daily.md", "work/daily.md"},
+			want:  "work/daily.md",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newLinkTarget(tt.oldTarget, tt.newName, tt.names); got != tt.want {
+				t.Errorf("newLinkTarget(%q, %q) = %q, want %q", tt.oldTarget, tt.newName, got, tt.want)
+			}
+		})
+	}
+}
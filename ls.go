@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// listOptions select and format the notes printed by "ks ls"
+type listOptions struct {
+	sortBy   string   // "name", "date" or "size"
+	reverse  bool     // reverse the sort order
+	limit    int      // print at most this many notes (0 = all)
+	patterns []string // globs the note names must match (any of them)
+	tag      string
+	format   string // "" (styled text), json, csv, tsv or a Go template
+}
+
+// listedNote is the representation of a note in the json, csv and tsv
+// formats, and the data the --format templates are executed with
+type listedNote struct {
+	Name     string    `json:"name"`
+	Folder   string    `json:"folder"`
+	Title    string    `json:"title"`
+	Tags     []string  `json:"tags"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// listedNoteColumns are the csv and tsv header row
+var listedNoteColumns = []string{"name", "folder", "title", "tags", "size", "modified"}
+
+// newListedNote converts a note for output
+func newListedNote(note noteInfo) listedNote {
+	folder := path.Dir(note.name)
+	if folder == "." {
+		folder = ""
+	}
+	tags := note.meta.tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return listedNote{
+		Name:     note.name,
+		Folder:   folder,
+		Title:    note.meta.title,
+		Tags:     tags,
+		Size:     note.size,
+		Modified: note.modTime,
+	}
+}
+
+// columns returns the note's csv and tsv fields, in the order of listedNoteColumns
+func (n listedNote) columns() []string {
+	return []string{
+		n.Name,
+		n.Folder,
+		n.Title,
+		strings.Join(n.Tags, " "),
+		fmt.Sprint(n.Size),
+		n.Modified.Format(time.RFC3339),
+	}
+}
+
+// matchNoteGlob reports whether a note name matches one of the patterns of "ks ls"
+// A pattern without a slash matches the file name in any folder ("*.md"), one
+// with a slash the whole name ("work/*.md"), and a plain folder name every note in it.
+func matchNoteGlob(pattern, name string) (bool, error) {
+	pattern = strings.TrimSuffix(pattern, "/")
+	if !strings.ContainsAny(pattern, "*?[\\") && strings.HasPrefix(name, pattern+"/") {
+		return true, nil
+	}
+	if !strings.Contains(pattern, "/") {
+		return path.Match(pattern, path.Base(name))
+	}
+	return path.Match(pattern, name)
+}
+
+// selectNotes filters, sorts and limits the notes for "ks ls"
+func selectNotes(notes []noteInfo, opts listOptions) ([]noteInfo, error) {
+	if opts.tag != "" {
+		notes = filterByTag(notes, opts.tag)
+	}
+	if len(opts.patterns) > 0 {
+		var matched []noteInfo
+		for _, note := range notes {
+			for _, pattern := range opts.patterns {
+				ok, err := matchNoteGlob(pattern, note.name)
+				if err != nil {
+					return nil, fmt.Errorf("%q: %v", pattern, err)
+				}
+				if ok {
+					matched = append(matched, note)
+					break
+				}
+			}
+		}
+		notes = matched
+	}
+
+	notes = sortNotes(notes, opts.sortBy)
+	if opts.reverse {
+		slices.Reverse(notes)
+	}
+	if opts.limit > 0 && len(notes) > opts.limit {
+		notes = notes[:opts.limit]
+	}
+	return notes, nil
+}
+
+// errUnknownFormat is returned for a --format that is neither a format name nor a template
+var errUnknownFormat = errors.New("unknown format")
+
+// writeNoteList prints notes in one of the --format formats
+func writeNoteList(w io.Writer, notes []noteInfo, format string) error {
+	listed := make([]listedNote, len(notes))
+	for i, note := range notes {
+		listed[i] = newListedNote(note)
+	}
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(listed)
+
+	case "csv":
+		writer := csv.NewWriter(w)
+		writer.Write(listedNoteColumns)
+		for _, note := range listed {
+			writer.Write(note.columns())
+		}
+		writer.Flush()
+		return writer.Error()
+
+	case "tsv":
+		// Tabs and newlines inside a field would break the columns
+		clean := strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+		fmt.Fprintln(w, strings.Join(listedNoteColumns, "\t"))
+		for _, note := range listed {
+			fields := note.columns()
+			for i := range fields {
+				fields[i] = clean.Replace(fields[i])
+			}
+			if _, err := fmt.Fprintln(w, strings.Join(fields, "\t")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Anything else must be a template, executed once per note
+	if !strings.Contains(format, "{{") {
+		return errUnknownFormat
+	}
+	tmpl, err := template.New("format").Funcs(template.FuncMap{
+		"join": strings.Join,
+		"size": formatSize,
+	}).Parse(format)
+	if err != nil {
+		return err
+	}
+	for _, note := range listed {
+		var line strings.Builder
+		if err := tmpl.Execute(&line, note); err != nil {
+			return err
+		}
+		if !strings.HasSuffix(line.String(), "\n") {
+			line.WriteString("\n")
+		}
+		if _, err := io.WriteString(w, line.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printNoteList prints every note matching the options (CLI version, exits on error)
+func printNoteList(notesDir string, opts listOptions) {
+	notes, err := walkNotes(notesDir)
+	if err != nil {
+		failErr(err, "Could not read the notes directory")
+	}
+	notes, err = selectNotes(notes, opts)
+	if err != nil {
+		fail(exitUsage, "Invalid pattern %v", err)
+	}
+
+	if opts.format != "" {
+		err := writeNoteList(os.Stdout, notes, opts.format)
+		if err == errUnknownFormat {
+			fail(exitUsage, "Unknown format '%s' (use json, csv, tsv or a template such as '{{.Name}}')", opts.format)
+		}
+		if err != nil {
+			fail(exitUsage, "Invalid format: %v", err)
+		}
+		return
+	}
+
+	if len(notes) == 0 {
+		if opts.tag != "" {
+			fmt.Printf("No notes tagged #%s.\n", opts.tag)
+			return
+		}
+		fmt.Println("No notes found.")
+		return
+	}
+
+	fmt.Println(theme.Header.Render("Notes:"))
+	for _, note := range notes {
+		timeStr := note.modTime.Format("2006-01-02 15:04")
+		sizeStr := formatSize(note.size)
+		nameStyled := theme.Primary.Render(note.name)
+		metaStyled := theme.Secondary.Render(fmt.Sprintf("%8s  (modified: %s)", sizeStr, timeStr))
+		if len(note.meta.tags) > 0 {
+			metaStyled += " " + theme.Accent.Render(formatTags(note.meta.tags))
+		}
+		fmt.Printf("  • %s %s\n", nameStyled, metaStyled)
+	}
+}
+
+// runLsCommand handles "ks ls [flags] [pattern...]"
+func runLsCommand(args []string) {
+	fs := flag.NewFlagSet("ls", flag.ExitOnError)
+	opts := listOptions{sortBy: config.Sort}
+	fs.StringVar(&opts.tag, "t", "", "Only notes with a tag")
+	fs.StringVar(&opts.tag, "tag", "", "Only notes with a tag")
+	fs.StringVar(&opts.sortBy, "sort", opts.sortBy, "Sort by name, date or size")
+	fs.BoolVar(&opts.reverse, "r", false, "Reverse the order")
+	fs.BoolVar(&opts.reverse, "reverse", false, "Reverse the order")
+	fs.IntVar(&opts.limit, "n", 0, "Show at most this many notes")
+	fs.IntVar(&opts.limit, "limit", 0, "Show at most this many notes")
+	fs.StringVar(&opts.format, "f", "", "Output format: json, csv, tsv or a Go template")
+	fs.StringVar(&opts.format, "format", "", "Output format: json, csv, tsv or a Go template")
+	fs.Usage = func() { printLsUsage(os.Stderr) }
+	opts.patterns = parseArgs(fs, args)
+
+	switch opts.sortBy {
+	case "name", "date", "size":
+	default:
+		fail(exitUsage, "--sort must be name, date or size")
+	}
+	if opts.limit < 0 {
+		fail(exitUsage, "--limit can't be negative")
+	}
+	opts.tag = normalizeTag(opts.tag)
+
+	printNoteList(cliNotesDir(), opts)
+}
+
+// printLsUsage shows the help for "ks ls"
+func printLsUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ks ls [flags] [pattern...]")
+	fmt.Fprintln(w, "\nLists every note, in all folders. Patterns are globs: one without a slash")
+	fmt.Fprintln(w, "matches the file name in any folder ('*.md'), one with a slash the whole")
+	fmt.Fprintln(w, "name ('work/*.md'), and a folder name every note in it ('work').")
+	fmt.Fprintln(w, "\nFlags:")
+	fmt.Fprintln(w, "  -t, --tag <tag>          Only notes with a tag")
+	fmt.Fprintln(w, "      --sort <mode>        name, date (newest first) or size (largest first)")
+	fmt.Fprintln(w, "  -r, --reverse            Reverse the order")
+	fmt.Fprintln(w, "  -n, --limit <n>          Show at most n notes")
+	fmt.Fprintln(w, "  -f, --format <format>    json, csv, tsv or a Go template")
+	fmt.Fprintln(w, "\nThe json, csv and tsv formats have the fields name, folder, title, tags,")
+	fmt.Fprintln(w, "size and modified. Templates see them as {{.Name}}, {{.Folder}}, {{.Title}},")
+	fmt.Fprintln(w, "{{.Tags}}, {{.Size}} and {{.Modified}}, with the join and size functions:")
+	fmt.Fprintln(w, "  {{join .Tags \",\"}}   {{size .Size}}   {{.Modified.Format \"2006-01-02\"}}")
+	fmt.Fprintln(w, "\nExamples:")
+	fmt.Fprintln(w, "  ks ls --sort date --limit 5")
+	fmt.Fprintln(w, "  ks ls 'work/*' --format json | jq -r '.[].name'")
+	fmt.Fprintln(w, "  ks ls --tag meeting --format csv > meetings.csv")
+	fmt.Fprintln(w, "  ks ls --format '{{.Name}} {{.Size}}'")
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatchNoteGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.md", "inbox.md", true},
+		{"*.md", "work/standup.md", true},
+		{"*.md", "notes.txt", false},
+		{"standup.md", "work/standup.md", true},
+		{"w*", "work/standup.md", false}, // globs without a slash only see the file name
+		{"work/*.md", "work/standup.md", true},
+		{"work/*.md", "work/projects/plan.md", false},
+		{"*/*.md", "work/standup.md", true},
+		{"work/standup.md", "work/standup.md", true},
+		{"work", "work/projects/plan.md", true},
+		{"work/", "work/standup.md", true},
+		{"work/projects", "work/projects/plan.md", true},
+		{"wor", "work/standup.md", false},
+		{"work", "workshop.md", false},
+		{"work", "work", true}, // a note named like the pattern
+	}
+
+	for _, tt := range tests {
+		got, err := matchNoteGlob(tt.pattern, tt.name)
+		if err != nil {
+			t.Errorf("matchNoteGlob(%q, %q): %v", tt.pattern, tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("matchNoteGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := matchNoteGlob("[", "inbox.md"); err == nil {
+		t.Error("matchNoteGlob with a malformed pattern succeeded, want an error")
+	}
+}
+
+func TestWriteNoteList(t *testing.T) {
+	notes := []noteInfo{
+		{
+			name:    "work/standup.md",
+			size:    1536,
+			modTime: time.Date(2026, time.March, 2, 9, 30, 0, 0, time.UTC),
+			meta:    noteMeta{title: `Standup, "weekly"`, tags: []string{"meeting", "work"}},
+		},
+		{
+			name:    "inbox.md",
+			size:    12,
+			modTime: time.Date(2026, time.March, 1, 8, 0, 0, 0, time.UTC),
+			meta:    noteMeta{title: "Tab\there\r\nand a new line"},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{
+			name:   "csv quotes commas, quotes and line breaks",
+			format: "csv",
+			want: "name,folder,title,tags,size,modified\n" +
+				"work/standup.md,work,\"Standup, \"\"weekly\"\"\",meeting work,1536,2026-03-02T09:30:00Z\n" +
+				"inbox.md,,\"Tab\there\r\nand a new line\",,12,2026-03-01T08:00:00Z\n",
+		},
+		{
+			name:   "tsv replaces tabs and line breaks",
+			format: "tsv",
+			want: "name\tfolder\ttitle\ttags\tsize\tmodified\n" +
+				"work/standup.md\twork\tStandup, \"weekly\"\tmeeting work\t1536\t2026-03-02T09:30:00Z\n" +
+				"inbox.md\t\tTab here  and a new line\t\t12\t2026-03-01T08:00:00Z\n",
+		},
+		{
+			name:   "template",
+			format: `{{.Name}} ({{size .Size}}) {{join .Tags ","}}`,
+			want:   "work/standup.md (1.5 KB) meeting,work\ninbox.md (12 B) \n",
+		},
+		{
+			name:   "template ending in a new line",
+			format: "{{.Folder}}/{{.Title}}\n",
+			want:   "work/Standup, \"weekly\"\n/Tab\there\r\nand a new line\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := writeNoteList(&out, notes, tt.format); err != nil {
+				t.Fatalf("writeNoteList(%q): %v", tt.format, err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("writeNoteList(%q) =\n%q\nwant\n%q", tt.format, out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteNoteListJSON(t *testing.T) {
+	notes := []noteInfo{{name: "inbox.md", size: 12, modTime: time.Date(2026, time.March, 1, 8, 0, 0, 0, time.UTC)}}
+
+	var out strings.Builder
+	if err := writeNoteList(&out, notes, "json"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"name": "inbox.md"`, `"folder": ""`, `"tags": []`, `"size": 12`, `"modified": "2026-03-01T08:00:00Z"`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("json output doesn't contain %s:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestWriteNoteListInvalidFormat(t *testing.T) {
+	notes := []noteInfo{{name: "inbox.md"}}
+
+	tests := []struct {
+		format  string
+		unknown bool // errUnknownFormat rather than a template error
+	}{
+		{"yaml", true},
+		{"JSON", true},
+		{"{{.Name}", false},
+		{"{{.Missing}}", false},
+		{"{{nosuchfunc .Name}}", false},
+	}
+
+	for _, tt := range tests {
+		var out strings.Builder
+		err := writeNoteList(&out, notes, tt.format)
+		if err == nil {
+			t.Errorf("writeNoteList(%q) succeeded, want an error", tt.format)
+			continue
+		}
+		if errors.Is(err, errUnknownFormat) != tt.unknown {
+			t.Errorf("writeNoteList(%q) = %v, want errUnknownFormat: %v", tt.format, err, tt.unknown)
+		}
+	}
+}
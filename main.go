@@ -1,12 +1,18 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -277,36 +283,8 @@ var themes = map[string]func() Theme{
 
 // runREPL starts the interactive REPL mode
 func runREPL() {
-	for {
-		// Show main menu
-		m := newMenuModel()
-		p := tea.NewProgram(m, tea.WithAltScreen())
-		result, err := p.Run()
-		if err != nil {
-			fmt.Println(theme.Error.Render("✗ Error: " + err.Error()))
-			os.Exit(1)
-		}
-
-		menu := result.(menuModel)
-
-		switch menu.selected {
-		case "Notes":
-			listNotes("name", true)
-		case "New Note":
-			// Create note and then go to list
-			filename, content, ok := interactiveWrite()
-			if ok {
-				writeNoteQuiet(filename, content)
-				// Show list with notification
-				listNotesWithNotification("name", fmt.Sprintf("Created '%s'", filename))
-			}
-		case "Themes":
-			runThemeSelector()
-		case "Quit", "quit":
-			fmt.Println(theme.Success.Render("Goodbye!"))
-			return
-		}
-	}
+	runApp(newAppModel(menuScreen), tea.WithAltScreen())
+	fmt.Println(theme.Success.Render("Goodbye!"))
 }
 
 // runInteractiveSearch prompts for a search keyword and runs interactive search
@@ -318,11 +296,11 @@ func runInteractiveSearch() {
 	fmt.Print(theme.Primary.Render("Search: "))
 
 	// Simple input for now - could make this a full TUI
-	var keyword string
-	fmt.Scanln(&keyword)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	keyword := strings.TrimSpace(line)
 
 	if keyword != "" {
-		searchNotes(keyword, true)
+		runSearch([]string{keyword}, false)
 	}
 }
 
@@ -336,13 +314,14 @@ func runInteractiveCreate() {
 
 func main() {
 	// Define flag variables - keep it simple
-	var writeFlag, readFlag, deleteFlag, appendFlag, helpFlag bool
+	var writeFlag, readFlag, deleteFlag, appendFlag, searchFlag, helpFlag bool
 
 	// Register short flags
 	flag.BoolVar(&writeFlag, "w", false, "Write a note")
 	flag.BoolVar(&readFlag, "r", false, "Read a note")
 	flag.BoolVar(&deleteFlag, "d", false, "Delete a note")
 	flag.BoolVar(&appendFlag, "a", false, "Append to a note")
+	flag.BoolVar(&searchFlag, "s", false, "Search notes")
 	flag.BoolVar(&helpFlag, "h", false, "Show help message")
 
 	// Register long flags
@@ -350,37 +329,58 @@ func main() {
 	flag.BoolVar(&readFlag, "read", false, "Read a note")
 	flag.BoolVar(&deleteFlag, "delete", false, "Delete a note")
 	flag.BoolVar(&appendFlag, "append", false, "Append to a note")
+	flag.BoolVar(&searchFlag, "search", false, "Search notes")
 	flag.BoolVar(&helpFlag, "help", false, "Show help message")
 
 	// Force flag (skip confirmations)
 	var forceFlag bool
 	flag.BoolVar(&forceFlag, "force", false, "Skip confirmation prompts")
 
+	// Tag filter (list notes carrying a tag)
+	var tagFlag string
+	flag.StringVar(&tagFlag, "t", "", "List notes with a tag")
+	flag.StringVar(&tagFlag, "tag", "", "List notes with a tag")
+
+	// Regex mode for search
+	var regexFlag bool
+	flag.BoolVar(&regexFlag, "E", false, "Treat search terms as regular expressions")
+	flag.BoolVar(&regexFlag, "regex", false, "Treat search terms as regular expressions")
+
+	// Encrypt notes written with -w
+	flag.BoolVar(&encryptWrites, "encrypt", false, "Encrypt the note with a passphrase")
+
+	// External editor ($VISUAL/$EDITOR) instead of the built-in one
+	var editorFlag bool
+	flag.BoolVar(&editorFlag, "editor", false, "Edit notes in $VISUAL/$EDITOR")
+
 	// Custom usage message
-	flag.Usage = printUsage
+	flag.Usage = func() { printUsage(os.Stderr) }
 
 	// Parse the flags
 	flag.Parse()
 
+	// Load persisted settings (theme, sort mode, preview, notes directory)
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, theme.Warning.Render("⚠ Error reading config file: "+err.Error()))
+	}
+	config = cfg
+	applyTheme(config.Theme)
+
 	// Handle help flag explicitly
 	if helpFlag {
-		printUsage()
+		printUsage(os.Stdout)
 		os.Exit(0)
 	}
 
+	// --editor opens notes in $VISUAL/$EDITOR for this run
+	if editorFlag {
+		config.ExternalEditor = true
+	}
+
 	// Get remaining arguments after flags
 	args := flag.Args()
 
-	// If no flags provided, launch REPL mode
-	if flag.NFlag() == 0 {
-		if isTTY() {
-			runREPL()
-		} else {
-			printUsage()
-		}
-		return
-	}
-
 	// Check that only one flag is used at a time
 	flagCount := 0
 	if writeFlag {
@@ -395,154 +395,114 @@ func main() {
 	if appendFlag {
 		flagCount++
 	}
+	if searchFlag {
+		flagCount++
+	}
 
 	if flagCount > 1 {
-		fmt.Println("Error: Only one command flag can be used at a time")
-		printUsage()
-		os.Exit(1)
+		fail(exitUsage, "Only one command flag can be used at a time (see 'ks --help')")
 	}
 
-	// Execute the appropriate command based on flag
-	if writeFlag {
-		var filename, note string
+	// Subcommands
+	if flagCount == 0 && tagFlag == "" && len(args) > 0 {
+		cmd, ok := findCommand(args[0])
+		if !ok {
+			fail(exitUsage, "Unknown command '%s' (see 'ks --help')", args[0])
+		}
+		runCommand(cmd, args[1:])
+		return
+	}
 
-		// Multiple modes: 0 args (interactive), 1 arg (stdin or interactive content), 2 args (direct)
-		if len(args) == 0 {
-			// Interactive mode - prompt for filename and content
-			filename, note, ok := interactiveWrite()
-			if !ok {
-				fmt.Println("Write cancelled.")
-				os.Exit(1)
-			}
-			writeNote(filename, note)
-		} else if len(args) == 1 {
-			// Check if stdin has content
-			stdinContent, hasStdin := readFromStdin()
-			if hasStdin {
-				// stdin mode - use provided filename and stdin content
-				filename = args[0]
-				note = stdinContent
-				writeNote(filename, note)
-			} else {
-				// Interactive content mode - use provided filename, prompt for content
-				filename = args[0]
-				// Validate filename first
-				if err := validateFilename(filename); err != nil {
-					suggested := suggestFilename(filename)
-					if suggested != "" {
-						fmt.Printf("Invalid filename: %v\nSuggestion: %s\n", err, suggested)
-					} else {
-						fmt.Printf("Invalid filename: %v\n", err)
-					}
-					os.Exit(1)
-				}
-				note, ok := interactiveContent(filename)
-				if !ok {
-					fmt.Println("Write cancelled.")
-					os.Exit(1)
-				}
-				writeNote(filename, note)
-			}
-		} else if len(args) == 2 {
-			filename = args[0]
-			note = args[1]
-			writeNote(filename, note)
+	// If no command flags provided, launch REPL mode
+	if flagCount == 0 && tagFlag == "" {
+		if isTTY() {
+			runREPL()
 		} else {
-			fmt.Println("Usage: ks -w <filename> <note>")
-			fmt.Println("   or: ks --write <filename> <note>")
-			fmt.Println("   or: echo \"content\" | ks -w <filename>")
-			fmt.Println("   or: ks -w <filename> (interactive content)")
-			fmt.Println("   or: ks -w (fully interactive)")
-			os.Exit(1)
+			printUsage(os.Stdout)
 		}
+		return
+	}
+
+	// Tag filter on its own lists the matching notes
+	if flagCount == 0 {
+		listNotesByTag(config.Sort, tagFlag, true)
+		return
+	}
+
+	// The flags are short for the matching subcommands
+	if writeFlag {
+		writeNote(noteAndText("Write", args, interactiveWrite, printWriteUsage))
 	} else if readFlag {
-		if len(args) != 1 {
-			fmt.Println("Usage: ks -r <filename>")
-			fmt.Println("   or: ks --read <filename>")
-			os.Exit(1)
-		}
-		readNote(args[0])
-	} else if deleteFlag {
-		if len(args) != 1 {
-			fmt.Println("Usage: ks -d <filename>")
-			fmt.Println("   or: ks --delete <filename>")
-			os.Exit(1)
+		runReadCommand(args)
+	} else if searchFlag {
+		// --tag narrows the search to a tag
+		if tagFlag != "" {
+			args = append(args, "tag:"+tagFlag)
 		}
-		deleteNote(args[0], forceFlag)
-	} else if appendFlag {
-		var filename, note string
-
-		// Multiple modes: 0 args (interactive), 1 arg (stdin or interactive content), 2 args (direct)
 		if len(args) == 0 {
-			// Interactive mode - prompt for filename and content
-			filename, note, ok := interactiveAppend()
-			if !ok {
-				fmt.Println("Append cancelled.")
-				os.Exit(1)
-			}
-			appendNote(filename, note)
-		} else if len(args) == 1 {
-			// Check if stdin has content
-			stdinContent, hasStdin := readFromStdin()
-			if hasStdin {
-				// stdin mode - use provided filename and stdin content
-				filename = args[0]
-				note = stdinContent
-				appendNote(filename, note)
-			} else {
-				// Interactive content mode - use provided filename, prompt for content
-				filename = args[0]
-				// Validate filename first
-				if err := validateFilename(filename); err != nil {
-					suggested := suggestFilename(filename)
-					if suggested != "" {
-						fmt.Printf("Invalid filename: %v\nSuggestion: %s\n", err, suggested)
-					} else {
-						fmt.Printf("Invalid filename: %v\n", err)
-					}
-					os.Exit(1)
-				}
-				note, ok := interactiveContent(filename)
-				if !ok {
-					fmt.Println("Append cancelled.")
-					os.Exit(1)
-				}
-				appendNote(filename, note)
+			if isTTY() {
+				runInteractiveSearch()
+				return
 			}
-		} else if len(args) == 2 {
-			filename = args[0]
-			note = args[1]
-			appendNote(filename, note)
-		} else {
-			fmt.Println("Usage: ks -a <filename> <note>")
-			fmt.Println("   or: ks --append <filename> <note>")
-			fmt.Println("   or: echo \"content\" | ks -a <filename>")
-			fmt.Println("   or: ks -a <filename> (interactive content)")
-			fmt.Println("   or: ks -a (fully interactive)")
-			os.Exit(1)
+			usageError(printSearchUsage)
+		}
+		runSearch(args, regexFlag)
+	} else if deleteFlag {
+		if forceFlag {
+			args = append([]string{"--force"}, args...)
 		}
+		runRmCommand(args)
+	} else if appendFlag {
+		appendNote(noteAndText("Append", args, interactiveAppend, printAppendUsage))
 	}
 }
 
 // printUsage displays the help message
-func printUsage() {
-	fmt.Println(theme.Header.Render(" ks - Keep Simple Notes "))
-	fmt.Println("\nUsage:")
-	fmt.Println("  ks                                Launch interactive REPL menu")
-	fmt.Println("  ks [flags] [arguments]            Run specific command")
-	fmt.Println("\nFlags:")
-	fmt.Println("  -w, --write <filename> <note>    Write a note")
-	fmt.Println("  -a, --append <filename> <note>   Append to a note")
-	fmt.Println("  -r, --read <filename>            Read a note")
-	fmt.Println("  -d, --delete <filename>          Delete a note")
-	fmt.Println("  -h, --help                       Show this help")
-	fmt.Println("\nExamples:")
-	fmt.Println("  ks                                # Launch REPL menu")
-	fmt.Println("  ks -w note.txt \"My note\"          # Quick write")
-	fmt.Println("  ks -a note.txt \"More content\"     # Quick append")
-	fmt.Println("  ks -r note.txt                    # Read note")
-	fmt.Println("  ks -d note.txt                    # Delete note")
-	fmt.Println("\nTip: Run 'ks' without flags to access all features interactively!")
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, theme.Header.Render(" ks - Keep Simple Notes "))
+	fmt.Fprintln(w, "\nUsage:")
+	fmt.Fprintln(w, "  ks                                Launch interactive REPL menu")
+	fmt.Fprintln(w, "  ks <command> [arguments]          Run a command ('ks <command> --help' for its options)")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, cmd := range commands {
+		if cmd.hidden {
+			continue
+		}
+		fmt.Fprintf(w, "  %-34s%s\n", strings.TrimSpace("ks "+cmd.name+" "+cmd.args), cmd.summary)
+	}
+	fmt.Fprintln(w, "\nFlags:")
+	fmt.Fprintln(w, "  -w, --write <filename> <note>    Same as ks write")
+	fmt.Fprintln(w, "  -a, --append <filename> <note>   Same as ks append")
+	fmt.Fprintln(w, "  -r, --read <filename>            Same as ks read")
+	fmt.Fprintln(w, "  -d, --delete <filename...>       Same as ks rm (--force skips the confirmation)")
+	fmt.Fprintln(w, "  -s, --search <query...>          Same as ks search")
+	fmt.Fprintln(w, "  -t, --tag <tag>                  List notes with a tag")
+	fmt.Fprintln(w, "      --editor                     Edit notes in $VISUAL/$EDITOR")
+	fmt.Fprintln(w, "      --encrypt                    Encrypt the note written with -w")
+	fmt.Fprintln(w, "  -h, --help                       Show this help")
+	fmt.Fprintln(w, "\nExamples:")
+	fmt.Fprintln(w, "  ks                                # Launch REPL menu")
+	fmt.Fprintln(w, "  ks write note.txt \"My note\"       # Quick write (or ks -w)")
+	fmt.Fprintln(w, "  ks append note.txt \"More content\" # Quick append (or ks -a)")
+	fmt.Fprintln(w, "  ks write work/todo.md \"Ship it\"   # Write into a folder")
+	fmt.Fprintln(w, "  ks read note.txt                  # Read note")
+	fmt.Fprintln(w, "  ks mv note.txt work/note.txt      # Move a note into a folder")
+	fmt.Fprintln(w, "  ks rm note.txt                    # Delete note")
+	fmt.Fprintln(w, "  ks --tag meeting                  # List notes tagged #meeting")
+	fmt.Fprintln(w, "  ks search deploy -draft           # Notes with 'deploy' but not 'draft'")
+	fmt.Fprintln(w, "  ks today -a \"shipped X\"           # Log to today's journal note")
+	fmt.Fprintln(w, "\nExit codes:")
+	fmt.Fprintln(w, "  0  Success")
+	fmt.Fprintln(w, "  1  Other errors (and no matches for ks search)")
+	fmt.Fprintln(w, "  2  Unknown command, or wrong arguments or flags")
+	fmt.Fprintln(w, "  3  Note, version or trash entry not found")
+	fmt.Fprintln(w, "  4  Invalid note name")
+	fmt.Fprintln(w, "  5  Cancelled")
+	fmt.Fprintln(w, "  6  Reading or writing files failed")
+	fmt.Fprintln(w, "  7  The note already exists")
+	fmt.Fprintln(w, "\nErrors are printed to stderr.")
+	fmt.Fprintln(w, "Settings (theme, sort, preview, notes_dir) are saved in ~/.config/ks/config.toml")
+	fmt.Fprintln(w, "Tip: Run 'ks' without flags to access all features interactively!")
 }
 
 // getNotesDir returns the path to the notes directory
@@ -553,8 +513,14 @@ func getNotesDir() (string, error) {
 		return "", err
 	}
 
-	// Build the notes directory path: ~/.local/share/ks
+	// Build the notes directory path: ~/.local/share/ks (or notes_dir from the config file)
 	notesDir := filepath.Join(home, ".local", "share", "ks")
+	if config.NotesDir != "" {
+		notesDir, err = expandPath(config.NotesDir)
+		if err != nil {
+			return "", err
+		}
+	}
 
 	// Create the directory if it doesn't exist
 	err = os.MkdirAll(notesDir, 0755)
@@ -565,16 +531,37 @@ func getNotesDir() (string, error) {
 	return notesDir, nil
 }
 
+// getStateDir returns the hidden folder inside the notes directory where ks keeps its own data
+func getStateDir() (string, error) {
+	notesDir, err := getNotesDir()
+	if err != nil {
+		return "", err
+	}
+
+	stateDir := filepath.Join(notesDir, ".ks")
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return "", err
+	}
+
+	return stateDir, nil
+}
+
 // validateFilename ensures the filename is safe and doesn't contain path traversal attempts
+// Forward slashes are allowed to place notes in notebook folders (e.g. "work/standup.md")
 func validateFilename(filename string) error {
 	// Check for empty filename
 	if filename == "" {
 		return fmt.Errorf("filename cannot be empty")
 	}
 
-	// Check for path separators (prevents directory traversal)
-	if strings.Contains(filename, "/") || strings.Contains(filename, "\\") {
-		return fmt.Errorf("filename cannot contain path separators (/ or \\)")
+	// Only forward slashes separate folders
+	if strings.Contains(filename, "\\") {
+		return fmt.Errorf("filename cannot contain backslashes (use / for folders)")
+	}
+
+	// Absolute paths would escape the notes directory
+	if strings.HasPrefix(filename, "/") {
+		return fmt.Errorf("filename cannot start with '/'")
 	}
 
 	// Check for parent directory references
@@ -582,14 +569,26 @@ func validateFilename(filename string) error {
 		return fmt.Errorf("filename cannot contain '..'")
 	}
 
-	// Check if filename starts with a dot (hidden files - optional security measure)
-	if strings.HasPrefix(filename, ".") {
-		return fmt.Errorf("filename cannot start with '.' (hidden files not allowed)")
+	// Check each folder component
+	for _, part := range strings.Split(filename, "/") {
+		if part == "" {
+			return fmt.Errorf("filename cannot contain empty folder names (//) or end with '/'")
+		}
+
+		// Hidden files and folders are not allowed (optional security measure)
+		if strings.HasPrefix(part, ".") {
+			return fmt.Errorf("filename cannot start with '.' (hidden files not allowed)")
+		}
 	}
 
 	return nil
 }
 
+// notePath returns the full path of a (validated) note name inside the notes directory
+func notePath(notesDir, filename string) string {
+	return filepath.Join(notesDir, filepath.FromSlash(filename))
+}
+
 // readFromStdin reads content from standard input
 // Returns the content and true if stdin has data, or empty string and false if not
 func readFromStdin() (string, bool) {
@@ -608,7 +607,7 @@ func readFromStdin() (string, bool) {
 	// Read all data from stdin
 	data, err := io.ReadAll(os.Stdin)
 	if err != nil {
-		fmt.Printf("Error reading from stdin: %v\n", err)
+		printError("Could not read from stdin: %v", err)
 		return "", false
 	}
 
@@ -705,7 +704,7 @@ func confirm(question string) bool {
 	p := tea.NewProgram(m)
 	finalModel, err := p.Run()
 	if err != nil {
-		fmt.Printf("Error running confirmation: %v\n", err)
+		printError("Could not ask for confirmation: %v", err)
 		return false
 	}
 
@@ -717,7 +716,7 @@ func confirm(question string) bool {
 
 // writeInputModel handles interactive write mode
 type writeInputModel struct {
-	state         int // 0 = filename input, 1 = content input, 2 = done
+	state         int // 0 = filename input, 1 = content input, 2 = done, 3 = template picker, 4 = template placeholders
 	filenameInput textinput.Model
 	contentInput  textarea.Model
 	filename      string
@@ -726,6 +725,16 @@ type writeInputModel struct {
 	quitting      bool
 	width         int
 	height        int
+
+	// Template picker, shown after the filename when there are templates
+	notesDir       string
+	templates      []string
+	templateCursor int // 0 = blank note, 1.. = templates[cursor-1]
+	templateText   string
+	vars           map[string]string
+	placeholders   []string // the template's own variables, asked for one by one
+	placeholder    int
+	varInput       textinput.Model
 }
 
 func newWriteInputModel() writeInputModel {
@@ -738,6 +747,8 @@ func newWriteInputModel() writeInputModel {
 	ta.ShowLineNumbers = false
 	ta.CharLimit = 0
 
+	notesDir, _ := getNotesDir()
+
 	return writeInputModel{
 		state:         0,
 		filenameInput: ti,
@@ -746,7 +757,50 @@ func newWriteInputModel() writeInputModel {
 		quitting:      false,
 		width:         0,
 		height:        0,
+		notesDir:      notesDir,
+		templates:     listTemplates(notesDir),
+		varInput:      textinput.New(),
+	}
+}
+
+// startContent moves on to the content input, filled with the given text
+func (m writeInputModel) startContent(text string) (writeInputModel, tea.Cmd) {
+	m.state = 1
+	m.validationErr = ""
+	// Resize textarea for fullscreen
+	if m.width > 0 && m.height > 0 {
+		m.contentInput.SetWidth(m.width - 4)
+		m.contentInput.SetHeight(m.height - 10)
+	}
+	m.contentInput.SetValue(text)
+	m.contentInput.Focus()
+	return m, textarea.Blink
+}
+
+// pickTemplate starts the note from the template under the cursor, asking
+// for its placeholders first
+func (m writeInputModel) pickTemplate() (writeInputModel, tea.Cmd) {
+	if m.templateCursor == 0 {
+		return m.startContent("")
+	}
+
+	text, err := readTemplate(m.notesDir, m.templates[m.templateCursor-1])
+	if err != nil {
+		m.validationErr = err.Error()
+		return m, nil
+	}
+	m.templateText = text
+	m.vars = templateVars(m.filename, time.Now())
+	m.placeholders = templatePlaceholders(text, m.vars)
+	m.placeholder = 0
+	if len(m.placeholders) == 0 {
+		return m.startContent(expandTemplate(text, m.vars))
 	}
+	m.state = 4
+	m.validationErr = ""
+	m.varInput.SetValue("")
+	m.varInput.Focus()
+	return m, textinput.Blink
 }
 
 func (m writeInputModel) Init() tea.Cmd {
@@ -773,8 +827,28 @@ func (m writeInputModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				// Ctrl+C or Esc in content mode asks for confirmation
 				return m, nil
 			}
+			if msg.String() == "esc" && (m.state == 3 || m.state == 4) {
+				// Back to the previous step
+				m.validationErr = ""
+				if m.state == 4 {
+					m.state = 3
+					return m, nil
+				}
+				m.state = 0
+				return m, textinput.Blink
+			}
 			m.quitting = true
-			return m, tea.Quit
+			return m, screenDone
+
+		case "up", "k", "down", "j":
+			if m.state == 3 {
+				if msg.String() == "up" || msg.String() == "k" {
+					m.templateCursor = max(m.templateCursor-1, 0)
+				} else {
+					m.templateCursor = min(m.templateCursor+1, len(m.templates))
+				}
+				return m, nil
+			}
 
 		case "enter":
 			if m.state == 0 {
@@ -796,18 +870,26 @@ func (m writeInputModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m, nil
 				}
 
-				// Filename is valid, move to content input
+				// Filename is valid, pick a template or move to content input
 				m.filename = filename
 				m.validationErr = ""
-				m.state = 1
-				// Resize textarea for fullscreen
-				if m.width > 0 && m.height > 0 {
-					m.contentInput.SetWidth(m.width - 4)
-					m.contentInput.SetHeight(m.height - 10)
+				if len(m.templates) > 0 {
+					m.state = 3
+					return m, nil
 				}
-				m.contentInput.Focus()
-				return m, textarea.Blink
-
+				return m.startContent("")
+			}
+			if m.state == 3 {
+				return m.pickTemplate()
+			}
+			if m.state == 4 {
+				m.vars[m.placeholders[m.placeholder]] = strings.TrimSpace(m.varInput.Value())
+				m.placeholder++
+				if m.placeholder == len(m.placeholders) {
+					return m.startContent(expandTemplate(m.templateText, m.vars))
+				}
+				m.varInput.SetValue("")
+				return m, nil
 			}
 
 		case "tab":
@@ -827,7 +909,7 @@ func (m writeInputModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.content = m.contentInput.Value()
 				m.state = 2
 				m.quitting = true
-				return m, tea.Quit
+				return m, screenDone
 			}
 		}
 	}
@@ -837,6 +919,8 @@ func (m writeInputModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.filenameInput, cmd = m.filenameInput.Update(msg)
 	} else if m.state == 1 {
 		m.contentInput, cmd = m.contentInput.Update(msg)
+	} else if m.state == 4 {
+		m.varInput, cmd = m.varInput.Update(msg)
 	}
 
 	return m, cmd
@@ -858,25 +942,37 @@ func (m writeInputModel) View() string {
 		}
 
 		content.WriteString("\n" + theme.Muted.Render("Enter to continue • Esc to cancel"))
+		return m.centered(content.String())
 
-		// Center vertically
-		contentStr := content.String()
-		contentHeight := strings.Count(contentStr, "\n") + 1
-		topPadding := 0
-		if m.height > contentHeight {
-			topPadding = (m.height - contentHeight) / 2
+	} else if m.state == 3 {
+		// Template picker - centered
+		var content strings.Builder
+		content.WriteString(theme.Primary.Render("New Note: ") + theme.Accent.Render(m.filename) + "\n\n")
+		content.WriteString(theme.Secondary.Render("Start from a template:") + "\n\n")
+		for i, name := range append([]string{"Blank note"}, m.templates...) {
+			if m.templateCursor == i {
+				content.WriteString(theme.Selected.Render("› "+name) + "\n")
+			} else {
+				content.WriteString(theme.Muted.Render("  "+name) + "\n")
+			}
 		}
 
-		if topPadding > 0 {
-			contentStr = strings.Repeat("\n", topPadding) + contentStr
+		if m.validationErr != "" {
+			content.WriteString("\n" + theme.Error.Render("✗ "+m.validationErr) + "\n")
 		}
 
-		// Center horizontally
-		style := lipgloss.NewStyle().
-			Width(m.width).
-			Align(lipgloss.Center)
+		content.WriteString("\n" + theme.Muted.Render("↑/↓: choose • Enter: use • Esc: back"))
+		return m.centered(content.String())
 
-		return style.Render(contentStr)
+	} else if m.state == 4 {
+		// Template placeholders - centered
+		var content strings.Builder
+		content.WriteString(theme.Primary.Render("New Note: ") + theme.Accent.Render(m.filename) + "\n\n")
+		content.WriteString(theme.Primary.Render(m.placeholders[m.placeholder]+":") +
+			theme.Muted.Render(fmt.Sprintf(" (%d/%d)", m.placeholder+1, len(m.placeholders))) + "\n\n")
+		content.WriteString(m.varInput.View() + "\n")
+		content.WriteString("\n" + theme.Muted.Render("Enter to continue • Esc: back"))
+		return m.centered(content.String())
 
 	} else if m.state == 1 {
 		// Content input stage - fullscreen
@@ -899,18 +995,45 @@ func (m writeInputModel) View() string {
 	return ""
 }
 
+// centered places a step of the form in the middle of the screen
+func (m writeInputModel) centered(content string) string {
+	// Center vertically
+	contentHeight := strings.Count(content, "\n") + 1
+	topPadding := 0
+	if m.height > contentHeight {
+		topPadding = (m.height - contentHeight) / 2
+	}
+
+	if topPadding > 0 {
+		content = strings.Repeat("\n", topPadding) + content
+	}
+
+	// Center horizontally
+	style := lipgloss.NewStyle().
+		Width(m.width).
+		Align(lipgloss.Center)
+
+	return style.Render(content)
+}
+
 // suggestFilename attempts to fix common filename issues
 func suggestFilename(filename string) string {
-	// Replace path separators with dashes
-	suggested := strings.ReplaceAll(filename, "/", "-")
-	suggested = strings.ReplaceAll(suggested, "\\", "-")
-
-	// Remove leading dots
-	suggested = strings.TrimPrefix(suggested, ".")
+	// Backslashes become folder separators
+	suggested := strings.ReplaceAll(filename, "\\", "/")
 
 	// Remove parent directory references
 	suggested = strings.ReplaceAll(suggested, "..", "")
 
+	// Drop empty folder names and leading dots from every component
+	var parts []string
+	for _, part := range strings.Split(suggested, "/") {
+		part = strings.TrimLeft(part, ".")
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	suggested = strings.Join(parts, "/")
+
 	// If we made changes, return the suggestion
 	if suggested != filename && suggested != "" {
 		return suggested
@@ -921,21 +1044,10 @@ func suggestFilename(filename string) string {
 
 // interactiveWrite launches the interactive write mode
 func interactiveWrite() (string, string, bool) {
-	m := newWriteInputModel()
-	p := tea.NewProgram(m, tea.WithAltScreen())
-	finalModel, err := p.Run()
-
-	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		return "", "", false
+	app := runApp(newAppModel(newNoteScreen), tea.WithAltScreen())
+	if app.newNote.state == 2 {
+		return app.newNote.filename, app.newNote.content, true
 	}
-
-	if final, ok := finalModel.(writeInputModel); ok {
-		if final.state == 2 {
-			return final.filename, final.content, true
-		}
-	}
-
 	return "", "", false
 }
 
@@ -944,12 +1056,14 @@ func interactiveAppend() (string, string, bool) {
 	return interactiveWrite() // Same logic for now
 }
 
-// interactiveContent prompts only for content (filename already provided)
-func interactiveContent(filename string) (string, bool) {
+// interactiveContent prompts only for content (filename already provided),
+// starting from the given text (e.g. an expanded template)
+func interactiveContent(filename, initial string) (string, bool) {
 	ta := textarea.New()
 	ta.Placeholder = "Write your note here..."
 	ta.ShowLineNumbers = false
 	ta.CharLimit = 0
+	ta.SetValue(initial)
 	ta.Focus()
 
 	m := writeInputModel{
@@ -962,20 +1076,12 @@ func interactiveContent(filename string) (string, bool) {
 		quitting:      false,
 	}
 
-	p := tea.NewProgram(m)
-	finalModel, err := p.Run()
-
-	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		return "", false
-	}
-
-	if final, ok := finalModel.(writeInputModel); ok {
-		if final.state == 2 {
-			return final.content, true
-		}
+	app := newAppModel(newNoteScreen)
+	app.newNote = m
+	app = runApp(app)
+	if app.newNote.state == 2 {
+		return app.newNote.content, true
 	}
-
 	return "", false
 }
 
@@ -988,8 +1094,25 @@ type noteEditorModel struct {
 	quitting bool
 	width    int
 	height   int
+	status   string // error from the last save attempt, shown above the footer
+	notice   string // result of a reload or merge, shown above the footer
+	follow   string // note to open next, from following a [[link]] with Ctrl+G
+
+	passphrase string // the note is encrypted with this passphrase ("" = plain text)
+
+	// Detecting changes made to the file by someone else while it is open
+	filePath    string      // "" = don't check
+	base        string      // file content when it was opened (or last reloaded/merged)
+	opened      fileVersion // version of the file matching base
+	diskChanged bool        // asking whether to overwrite, reload or merge
+	disk        string      // content found on disk when the change was detected
+	diskVersion fileVersion
+	choice      int // 0 = overwrite, 1 = reload, 2 = merge
 }
 
+// editorChoices are the options offered when the note changed on disk
+var editorChoices = []string{"Overwrite", "Reload", "Merge"}
+
 func newNoteEditorModel(filename, content string) noteEditorModel {
 	ta := textarea.New()
 	ta.Placeholder = "Write your note here..."
@@ -1009,6 +1132,84 @@ func newNoteEditorModel(filename, content string) noteEditorModel {
 	}
 }
 
+// watchFile makes the editor check, before saving, that the file still matches
+// the version it was opened from
+func (m *noteEditorModel) watchFile(filePath string, version fileVersion) {
+	m.filePath = filePath
+	m.base = m.content
+	m.opened = version
+}
+
+// resolveDiskChange applies the chosen way of handling a note that changed on disk
+func (m noteEditorModel) resolveDiskChange(choice int) (tea.Model, tea.Cmd) {
+	m.diskChanged = false
+	m.status = ""
+
+	switch choice {
+	case 0:
+		// Overwrite: save our version anyway
+		m.content = m.textarea.Value()
+		m.saved = true
+		m.quitting = true
+		return m, screenDone
+	case 1:
+		// Reload: drop our edits and continue from the version on disk
+		m.follow = ""
+		m.textarea.SetValue(m.disk)
+		m.notice = "Reloaded the version on disk"
+	case 2:
+		// Merge: combine both versions, marking lines changed on both sides
+		m.follow = ""
+		merged, conflicts := mergeText(m.base, m.textarea.Value(), m.disk)
+		m.textarea.SetValue(merged)
+		m.notice = "Merged with the version on disk"
+		if conflicts > 0 {
+			m.notice += fmt.Sprintf(" - %d conflicts between <<<<<<< and >>>>>>>, fix them and save", conflicts)
+		}
+	}
+
+	m.base = m.disk
+	m.opened = m.diskVersion
+	return m, nil
+}
+
+// save finishes editing with the note's new content, first making sure nobody
+// changed the note since it was opened
+func (m noteEditorModel) save() (tea.Model, tea.Cmd) {
+	if m.filePath != "" {
+		if disk, version, err := readFileVersion(m.filePath); err == nil && version != m.opened {
+			m.diskChanged = true
+			m.disk = string(disk)
+			if text, _, err := openNoteText(m.filename, disk); err == nil {
+				m.disk = text
+			}
+			m.diskVersion = version
+			m.choice = 0
+			return m, nil
+		}
+	}
+
+	m.content = m.textarea.Value()
+	m.saved = true
+	m.quitting = true
+	return m, screenDone
+}
+
+// linkUnderCursor returns the target of the [[link]] the cursor is on
+func (m noteEditorModel) linkUnderCursor() (string, bool) {
+	lines := strings.Split(m.textarea.Value(), "\n")
+	row := m.textarea.Line()
+	if row >= len(lines) {
+		return "", false
+	}
+
+	line := []rune(lines[row])
+	info := m.textarea.LineInfo()
+	col := min(info.StartColumn+info.ColumnOffset, len(line))
+	link, ok := linkAt(string(line), len(string(line[:col])))
+	return link.target, ok
+}
+
 func (m noteEditorModel) Init() tea.Cmd {
 	return textarea.Blink
 }
@@ -1025,22 +1226,65 @@ func (m noteEditorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.textarea.SetHeight(msg.Height - 8)
 
 	case tea.KeyMsg:
+		// The note changed on disk: overwrite, reload or merge?
+		if m.diskChanged {
+			switch msg.String() {
+			case "left", "h", "shift+tab":
+				m.choice = (m.choice + len(editorChoices) - 1) % len(editorChoices)
+			case "right", "l", "tab":
+				m.choice = (m.choice + 1) % len(editorChoices)
+			case "o", "O":
+				return m.resolveDiskChange(0)
+			case "r", "R":
+				return m.resolveDiskChange(1)
+			case "m", "M":
+				return m.resolveDiskChange(2)
+			case "enter":
+				return m.resolveDiskChange(m.choice)
+			case "esc":
+				// Back to editing
+				m.diskChanged = false
+				m.follow = ""
+			case "ctrl+c":
+				m.quitting = true
+				return m, screenDone
+			}
+			return m, nil
+		}
+
 		switch msg.String() {
 		case "ctrl+s":
-			// Save the note
-			m.content = m.textarea.Value()
-			m.saved = true
-			m.quitting = true
-			return m, tea.Quit
+			return m.save()
+
+		case "ctrl+g":
+			// Follow the [[link]] under the cursor, saving first if the note was changed
+			target, ok := m.linkUnderCursor()
+			if !ok {
+				m.status = "Put the cursor on a [[link]] to follow it"
+				return m, nil
+			}
+			notesDir, _ := getNotesDir()
+			name, found := findNote(notesDir, target)
+			if !found {
+				m.status = fmt.Sprintf("No note named '%s'", target)
+				return m, nil
+			}
+			m.status = ""
+			m.follow = name
+			if m.textarea.Value() == m.content {
+				m.quitting = true
+				return m, screenDone
+			}
+			return m.save()
 
 		case "esc":
 			// Quit without saving
 			m.quitting = true
-			return m, tea.Quit
+			return m, screenDone
 
 		case "ctrl+c":
 			m.quitting = true
-			return m, tea.Quit
+			return m, screenDone
 		}
 	}
 
@@ -1058,7 +1302,12 @@ func (m noteEditorModel) View() string {
 	header := theme.Primary.Render("Editing: ") + theme.Accent.Render(m.filename)
 
 	// Footer
-	footer := theme.Muted.Render("Ctrl+S: save • Esc: cancel")
+	footer := theme.Muted.Render("Ctrl+S: save • Ctrl+G: follow [[link]] • Esc: cancel")
+	if m.status != "" {
+		footer = theme.Error.Render("✗ "+m.status) + "\n" + footer
+	} else if m.notice != "" {
+		footer = theme.Warning.Render(m.notice) + "\n" + footer
+	}
 
 	// Build fullscreen layout
 	content := lipgloss.JoinVertical(
@@ -1070,26 +1319,119 @@ func (m noteEditorModel) View() string {
 		footer,
 	)
 
+	// Ask what to do about changes made on disk
+	if m.diskChanged {
+		var options []string
+		for i, choice := range editorChoices {
+			if i == m.choice {
+				options = append(options, theme.Selected.Render(" "+choice+" "))
+			} else {
+				options = append(options, theme.Unselected.Render(" "+choice+" "))
+			}
+		}
+
+		dialog := lipgloss.JoinVertical(
+			lipgloss.Center,
+			theme.Warning.Render(fmt.Sprintf("'%s' was changed on disk since you opened it", m.filename)),
+			"",
+			lipgloss.JoinHorizontal(lipgloss.Top, options[0], "  ", options[1], "  ", options[2]),
+			"",
+			theme.Muted.Render("Overwrite: save your version • Reload: discard your edits"),
+			theme.Muted.Render("Merge: combine both and keep editing"),
+			"",
+			theme.Muted.Render("←/→: select • Enter: confirm • Esc: keep editing"),
+		)
+
+		boxStyle := lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(theme.Warning.GetForeground()).
+			Padding(1, 2).
+			Width(64).
+			Align(lipgloss.Center)
+
+		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, boxStyle.Render(dialog), lipgloss.WithWhitespaceChars(" "))
+	}
+
 	return content
 }
 
+// editorFinishedMsg is sent when the external editor exits
+type editorFinishedMsg struct {
+	err error
+}
+
+// editorCommand builds the command for $VISUAL (or $EDITOR) to edit a file
+func editorCommand(filePath string) (*exec.Cmd, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+
+	// The variable may include arguments, e.g. "code --wait"
+	fields := strings.Fields(editor)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("set $VISUAL or $EDITOR to use an external editor")
+	}
+
+	args := append(fields[1:], filePath)
+	return exec.Command(fields[0], args...), nil
+}
+
+// runExternalEditor opens a file in the external editor and reports whether it changed
+func runExternalEditor(filePath string) (bool, error) {
+	cmd, err := editorCommand(filePath)
+	if err != nil {
+		return false, err
+	}
+
+	before, err := os.ReadFile(filePath)
+	if err != nil {
+		return false, err
+	}
+
+	// Outside the TUI the editor simply takes over the terminal
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return false, fmt.Errorf("editor failed: %v", err)
+	}
+
+	after, err := os.ReadFile(filePath)
+	if err != nil {
+		return false, err
+	}
+
+	return !bytes.Equal(before, after), nil
+}
+
 // noteListModel is an interactive list for browsing notes
 type noteListModel struct {
 	list             list.Model
 	viewport         viewport.Model
 	showPreview      bool
+	showHistory      bool   // preview panel shows the selected note's history instead of its content
+	historyName      string // note whose history panel is cached in historyPanel
+	historyPanel     string
+	backlinks        map[string][]string // note → notes linking to it (nil = not loaded yet)
 	notesDir         string
+	currentDir       string // folder being browsed, relative to notesDir ("" = top level)
+	tagFilter        string // when set, show notes from every folder carrying this tag
+	search           *searchQuery // when set, show the notes matching this search
 	sortMode         string // "name", "date", "size"
 	allNotes         []noteInfo
 	quitting         bool
 	selected         *noteInfo
-	action           string // "", "create", "rename", "delete"
+	action           string // "", "open", "view", "create", "rename", "delete", "bulk"
+	bulkKind         string // bulk action picked: "delete", "move", "tag", "export", "merge"
+	marked           map[string]bool // names of the marked notes (shared with the delegate)
+	markAnchor       string          // last note marked with space, where V starts its range
 	width            int
 	height           int
 	confirmingDelete bool
 	deleteCursor     int // 0 = No, 1 = Yes
 	notification     string
 	notificationTime time.Time
+	conflicts        []string // notes left with merge conflicts by "ks sync"
+	watcher          *notesWatcher
 }
 
 func newNoteListModel(notes []noteInfo, sortMode string) noteListModel {
@@ -1100,19 +1442,14 @@ func newNoteListModel(notes []noteInfo, sortMode string) noteListModel {
 	}
 
 	// Create list with custom delegate for styling
-	delegate := list.NewDefaultDelegate()
-	delegate.Styles.SelectedTitle = delegate.Styles.SelectedTitle.
-		Foreground(theme.Primary.GetForeground()).
-		BorderForeground(theme.Accent.GetForeground())
-	delegate.Styles.SelectedDesc = delegate.Styles.SelectedDesc.
-		Foreground(theme.Secondary.GetForeground())
-
-	l := list.New(items, delegate, 0, 0)
+	marked := make(map[string]bool)
+	l := list.New(items, markDelegate{newListDelegate(), marked}, 0, 0)
 	l.Title = "Notes"
 	l.Styles.Title = theme.Header
 	l.SetShowStatusBar(true)
 	l.SetFilteringEnabled(true)
 	l.SetShowHelp(true)
+	l.AdditionalFullHelpKeys = bulkHelpKeys
 
 	// Customize filter prompt to say "Search" instead of "Filter"
 	l.FilterInput.Prompt = "Search: "
@@ -1124,7 +1461,7 @@ func newNoteListModel(notes []noteInfo, sortMode string) noteListModel {
 	return noteListModel{
 		list:             l,
 		viewport:         vp,
-		showPreview:      true, // Preview visible by default
+		showPreview:      config.Preview, // Preview visible by default
 		notesDir:         notesDir,
 		sortMode:         sortMode,
 		allNotes:         notes,
@@ -1137,18 +1474,357 @@ func newNoteListModel(notes []noteInfo, sortMode string) noteListModel {
 		deleteCursor:     0,
 		notification:     "",
 		notificationTime: time.Time{},
+		conflicts:        gitConflicts(notesDir),
+		marked:           marked,
 	}
 }
 
-func (m noteListModel) Init() tea.Cmd {
-	// If there's a notification, start the clear timer
-	if m.notification != "" {
-		return clearNotificationAfter(3 * time.Second)
-	}
-	return nil
-}
-
-// clearNotificationMsg is sent after a delay to clear the notification
+// newListDelegate styles list items with the current theme
+func newListDelegate() list.DefaultDelegate {
+	delegate := list.NewDefaultDelegate()
+	delegate.Styles.SelectedTitle = delegate.Styles.SelectedTitle.
+		Foreground(theme.Primary.GetForeground()).
+		BorderForeground(theme.Accent.GetForeground())
+	delegate.Styles.SelectedDesc = delegate.Styles.SelectedDesc.
+		Foreground(theme.Secondary.GetForeground())
+	return delegate
+}
+
+// restyle applies a newly selected theme to the list
+func (m *noteListModel) restyle() {
+	m.list.SetDelegate(markDelegate{newListDelegate(), m.marked})
+	m.list.Styles.Title = theme.Header
+	m.historyName = "" // the history panel is rendered with theme colors
+	m.updatePreview()
+}
+
+// setDir records the folder being browsed and updates the list title
+func (m *noteListModel) setDir(dir string) {
+	m.currentDir = dir
+	m.list.Title = m.title()
+}
+
+// title builds the list title from the current folder, tag filter and sort mode
+func (m noteListModel) title() string {
+	title := "Notes"
+	if m.tagFilter != "" {
+		title += " tagged #" + m.tagFilter
+	} else if m.search != nil {
+		title = "Search Results for: " + m.search.raw
+	} else if m.currentDir != "" {
+		title += ": " + m.currentDir + "/"
+	}
+	if m.sortMode != "name" && m.sortMode != "relevance" {
+		title += fmt.Sprintf(" (sorted by: %s)", m.sortMode)
+	}
+	if len(m.marked) > 0 {
+		title += fmt.Sprintf(" • %d marked", len(m.marked))
+	}
+	return title
+}
+
+// toggleMark marks or unmarks the selected note
+func (m *noteListModel) toggleMark() {
+	item, ok := m.list.SelectedItem().(noteInfo)
+	if !ok || item.isDir {
+		return
+	}
+	if m.marked[item.name] {
+		delete(m.marked, item.name)
+	} else {
+		m.marked[item.name] = true
+	}
+	m.markAnchor = item.name
+	m.list.Title = m.title()
+}
+
+// markRange marks every note between the last one marked with space and the cursor
+func (m *noteListModel) markRange() {
+	items := m.list.VisibleItems()
+	from, to := -1, m.list.Index()
+	for i, item := range items {
+		if note, ok := item.(noteInfo); ok && note.name == m.markAnchor {
+			from = i
+		}
+	}
+	if from < 0 {
+		m.toggleMark()
+		return
+	}
+	for i := min(from, to); i <= max(from, to) && i < len(items); i++ {
+		if note, ok := items[i].(noteInfo); ok && !note.isDir {
+			m.marked[note.name] = true
+		}
+	}
+	m.list.Title = m.title()
+}
+
+// markAll marks every note shown, or unmarks them if they are all marked already
+func (m *noteListModel) markAll() {
+	var notes []string
+	allMarked := true
+	for _, item := range m.list.VisibleItems() {
+		if note, ok := item.(noteInfo); ok && !note.isDir {
+			notes = append(notes, note.name)
+			allMarked = allMarked && m.marked[note.name]
+		}
+	}
+	for _, name := range notes {
+		if allMarked {
+			delete(m.marked, name)
+		} else {
+			m.marked[name] = true
+		}
+	}
+	m.list.Title = m.title()
+}
+
+// clearMarks unmarks every note
+func (m *noteListModel) clearMarks() {
+	clear(m.marked)
+	m.markAnchor = ""
+	m.list.Title = m.title()
+}
+
+// markedNotes returns the marked notes in list order (ones in other folders last),
+// or the selected note when none are marked
+func (m noteListModel) markedNotes() []noteInfo {
+	if len(m.marked) == 0 {
+		if item, ok := m.list.SelectedItem().(noteInfo); ok && !item.isDir {
+			return []noteInfo{item}
+		}
+		return nil
+	}
+
+	var notes []noteInfo
+	seen := make(map[string]bool)
+	for _, item := range m.list.Items() {
+		if note, ok := item.(noteInfo); ok && m.marked[note.name] {
+			notes = append(notes, note)
+			seen[note.name] = true
+		}
+	}
+	// Notes marked in another folder or before a tag filter changed
+	if len(seen) < len(m.marked) {
+		others, _ := walkNotes(m.notesDir)
+		for _, note := range sortNotes(others, m.sortMode) {
+			if m.marked[note.name] && !seen[note.name] {
+				notes = append(notes, note)
+			}
+		}
+	}
+	return notes
+}
+
+// openDir loads the contents of a folder into the list
+func (m *noteListModel) openDir(dir string) {
+	m.currentDir = dir
+	m.list.ResetFilter()
+	m.reload()
+	m.list.Select(0)
+	m.updatePreview()
+}
+
+// setTagFilter switches the list to the notes carrying a tag ("" shows folders again)
+func (m *noteListModel) setTagFilter(tag string) {
+	m.tagFilter = tag
+	m.list.ResetFilter()
+	m.reload()
+	m.list.Select(0)
+	m.updatePreview()
+}
+
+// nextTag returns the tag after the current filter, cycling back to "" (no filter)
+func (m noteListModel) nextTag() string {
+	notes, err := walkNotes(m.notesDir)
+	if err != nil {
+		return ""
+	}
+
+	tags := collectTags(notes)
+	for i, tag := range tags {
+		if tag == m.tagFilter {
+			if i+1 < len(tags) {
+				return tags[i+1]
+			}
+			return ""
+		}
+	}
+	if m.tagFilter == "" && len(tags) > 0 {
+		return tags[0]
+	}
+	return ""
+}
+
+// reload refreshes the list items from disk for the current folder, tag filter or search
+func (m *noteListModel) reload() {
+	var notes []noteInfo
+	var err error
+	if m.tagFilter != "" {
+		notes, err = walkNotes(m.notesDir)
+		notes = filterByTag(notes, m.tagFilter)
+	} else if m.search != nil {
+		var results []rankedResult
+		results, _, err = indexedSearch(m.notesDir, *m.search)
+		for _, result := range results {
+			notes = append(notes, result.note)
+		}
+	} else {
+		notes, err = loadNotes(m.notesDir, m.currentDir)
+	}
+	if err != nil {
+		return
+	}
+
+	m.allNotes = sortNotes(notes, m.sortMode)
+	items := make([]list.Item, len(m.allNotes))
+	for i, note := range m.allNotes {
+		items[i] = note
+	}
+	// With a search filter active the list re-filters in a command - run it
+	// right away so the visible items are current
+	if cmd := m.list.SetItems(items); cmd != nil {
+		m.list, _ = m.list.Update(cmd())
+	}
+	m.list.Title = m.title()
+}
+
+// updatePreview loads the selected note (or folder listing) into the preview viewport
+func (m *noteListModel) updatePreview() {
+	if !m.showPreview {
+		return
+	}
+
+	item, ok := m.list.SelectedItem().(noteInfo)
+	if !ok {
+		m.viewport.SetContent("")
+		return
+	}
+
+	if item.isDir {
+		notes, err := loadNotes(m.notesDir, item.name)
+		if err != nil {
+			m.viewport.SetContent(theme.Error.Render("Error reading folder"))
+			return
+		}
+		if len(notes) == 0 {
+			m.viewport.SetContent(theme.Muted.Render("Empty folder"))
+			return
+		}
+
+		var content strings.Builder
+		for _, note := range sortNotes(notes, "name") {
+			content.WriteString(note.Title()[len(item.name)+1:] + "\n")
+		}
+		m.viewport.SetContent(content.String())
+		return
+	}
+
+	if m.showHistory {
+		// Diffing every version is too slow to redo on each key press
+		if m.historyName != item.name {
+			m.historyName = item.name
+			m.historyPanel = historyPanel(m.notesDir, item.name)
+		}
+		m.viewport.SetContent(m.historyPanel)
+		return
+	}
+
+	content, _, err := readNoteText(m.notesDir, item.name)
+	if err == errLocked {
+		m.viewport.SetContent(lockedPreview(item.name))
+	} else if err == nil {
+		m.viewport.SetContent(formatNote(item.name, content, m.viewport.Width) + m.backlinksPanel(item.name))
+	} else {
+		m.viewport.SetContent(theme.Error.Render("Error reading file"))
+	}
+}
+
+// backlinksPanel lists the notes linking to a note, for the bottom of the preview
+func (m *noteListModel) backlinksPanel(name string) string {
+	if m.backlinks == nil {
+		m.backlinks = linkIndex(m.notesDir).backlinks()
+	}
+	linking := m.backlinks[name]
+	if len(linking) == 0 {
+		return ""
+	}
+
+	var panel strings.Builder
+	panel.WriteString("\n" + theme.Muted.Render(strings.Repeat("─", max(m.viewport.Width, 1))) + "\n")
+	panel.WriteString(theme.Primary.Render(fmt.Sprintf("Backlinks (%d)", len(linking))) + "\n")
+	for _, other := range linking {
+		panel.WriteString(theme.Accent.Render("← ") + other + "\n")
+	}
+	return panel.String()
+}
+
+// refresh reloads the list after the notes changed on disk, keeping the
+// selected note, the search filter and the scroll position of the preview
+func (m *noteListModel) refresh() {
+	selectedName := ""
+	if item, ok := m.list.SelectedItem().(noteInfo); ok {
+		selectedName = item.name
+	}
+	index := m.list.Index()
+	yOffset := m.viewport.YOffset
+
+	// The folder being browsed may be gone - move up to the nearest one left
+	for m.tagFilter == "" && m.currentDir != "" {
+		if info, err := os.Stat(notePath(m.notesDir, m.currentDir)); err == nil && info.IsDir() {
+			break
+		}
+		m.setDir(strings.TrimPrefix(path.Dir(m.currentDir), "."))
+	}
+
+	// Marked notes may have been deleted or renamed
+	for name := range m.marked {
+		if _, err := os.Stat(notePath(m.notesDir, name)); err != nil {
+			delete(m.marked, name)
+		}
+	}
+
+	m.reload()
+	if !m.selectNote(selectedName) {
+		m.list.Select(min(index, max(len(m.list.VisibleItems())-1, 0)))
+	}
+
+	m.conflicts = gitConflicts(m.notesDir)
+	m.historyName = "" // the history panel may be out of date too
+	m.backlinks = nil  // and so may the links between notes
+	m.updatePreview()
+	if item, ok := m.list.SelectedItem().(noteInfo); ok && item.name == selectedName {
+		m.viewport.SetYOffset(yOffset)
+	}
+}
+
+// selectNote moves the cursor to a note, returning false if it isn't in the list
+func (m *noteListModel) selectNote(name string) bool {
+	for i, item := range m.list.VisibleItems() {
+		if note, ok := item.(noteInfo); ok && note.name == name {
+			m.list.Select(i)
+			return true
+		}
+	}
+	return false
+}
+
+func (m noteListModel) Init() tea.Cmd {
+	// If there's a notification, start the clear timer
+	if m.notification != "" {
+		return tea.Batch(clearNotificationAfter(3*time.Second), waitForChange(m.watcher))
+	}
+	return waitForChange(m.watcher)
+}
+
+// notify shows a message in the list's status line for a few seconds
+func (m *noteListModel) notify(notification string) tea.Cmd {
+	m.notification = notification
+	m.notificationTime = time.Now()
+	return clearNotificationAfter(3 * time.Second)
+}
+
+// clearNotificationMsg is sent after a delay to clear the notification
 type clearNotificationMsg struct{}
 
 func clearNotificationAfter(duration time.Duration) tea.Cmd {
@@ -1166,6 +1842,10 @@ func (m noteListModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.notificationTime = time.Time{}
 		return m, nil
 
+	case notesChangedMsg:
+		m.refresh()
+		return m, waitForChange(m.watcher)
+
 	case tea.KeyMsg:
 		// Handle delete confirmation dialog
 		if m.confirmingDelete {
@@ -1180,7 +1860,7 @@ func (m noteListModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				// Confirm delete
 				m.action = "delete"
 				m.quitting = true
-				return m, tea.Quit
+				return m, screenDone
 			case "n", "N", "esc":
 				// Cancel delete
 				m.confirmingDelete = false
@@ -1191,7 +1871,7 @@ func (m noteListModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					// User selected Yes
 					m.action = "delete"
 					m.quitting = true
-					return m, tea.Quit
+					return m, screenDone
 				} else {
 					// User selected No
 					m.confirmingDelete = false
@@ -1213,29 +1893,57 @@ func (m noteListModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		// Normal list navigation
 		switch msg.String() {
-		case "q", "esc":
+		case "esc", "backspace":
+			// Clear the search filter, leave tag filter mode, go up one folder,
+			// or go back to the menu from the top level
+			if msg.String() == "esc" && m.list.FilterState() == list.FilterApplied {
+				break
+			}
+			if msg.String() == "esc" && len(m.marked) > 0 {
+				m.clearMarks()
+				return m, nil
+			}
+			if m.tagFilter != "" {
+				m.setTagFilter("")
+				return m, nil
+			}
+			if m.currentDir != "" {
+				m.openDir(path.Dir(m.currentDir))
+				return m, nil
+			}
+			if msg.String() == "backspace" {
+				return m, nil
+			}
 			m.quitting = true
-			return m, tea.Quit
+			return m, screenDone
+
+		case "q":
+			m.quitting = true
+			return m, screenDone
 
 		case "ctrl+c":
 			m.action = "quit"
 			m.quitting = true
-			return m, tea.Quit
+			return m, screenDone
 
 		case "enter":
-			// Open selected note
+			// Open selected note, or drill into a folder
 			if item, ok := m.list.SelectedItem().(noteInfo); ok {
+				if item.isDir {
+					m.openDir(item.name)
+					return m, nil
+				}
 				m.selected = &item
 				m.action = "open"
 				m.quitting = true
-				return m, tea.Quit
+				return m, screenDone
 			}
 
 		case "n":
 			// Create new note
 			m.action = "create"
 			m.quitting = true
-			return m, tea.Quit
+			return m, screenDone
 
 		case "e":
 			// Rename selected note
@@ -1243,10 +1951,43 @@ func (m noteListModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.selected = &item
 				m.action = "rename"
 				m.quitting = true
-				return m, tea.Quit
+				return m, screenDone
+			}
+
+		case " ":
+			// Mark the selected note and move on to the next one
+			m.toggleMark()
+			m.list.CursorDown()
+			m.updatePreview()
+			return m, nil
+
+		case "V":
+			m.markRange()
+			return m, nil
+
+		case "*":
+			m.markAll()
+			return m, nil
+
+		case "m", "T", "x", "M":
+			// Act on the marked notes (or the selected one)
+			kinds := map[string]string{"m": "move", "T": "tag", "x": "export", "M": "merge"}
+			if len(m.markedNotes()) > 0 {
+				m.bulkKind = kinds[msg.String()]
+				m.action = "bulk"
+				m.quitting = true
+				return m, screenDone
 			}
+			return m, nil
 
 		case "d":
+			// Several notes are marked - confirm deleting them all
+			if len(m.marked) > 0 {
+				m.bulkKind = "delete"
+				m.action = "bulk"
+				m.quitting = true
+				return m, screenDone
+			}
 			// Show delete confirmation
 			if item, ok := m.list.SelectedItem().(noteInfo); ok {
 				m.selected = &item
@@ -1258,7 +1999,7 @@ func (m noteListModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "s":
 			// Cycle sort mode
 			switch m.sortMode {
-			case "name":
+			case "name", "relevance":
 				m.sortMode = "date"
 			case "date":
 				m.sortMode = "size"
@@ -1272,17 +2013,64 @@ func (m noteListModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				items[i] = note
 			}
 			m.list.SetItems(items)
-			m.list.Title = fmt.Sprintf("Notes (sorted by: %s)", m.sortMode)
+			m.list.Title = m.title()
+
+			// Remember the sort mode for next time
+			config.Sort = m.sortMode
+			if err := saveConfigValue("sort", quoteConfigString(m.sortMode)); err != nil {
+				return m, m.notify("Could not save the sort order: " + err.Error())
+			}
+			return m, nil
+
+		case "t":
+			// Cycle tag filter (all notes → #tag1 → #tag2 → ... → all notes)
+			m.setTagFilter(m.nextTag())
+			return m, nil
+
+		case "H":
+			// Toggle the history panel (opens the preview panel if needed)
+			m.showHistory = !m.showHistory
+			if m.showHistory && !m.showPreview {
+				m.showPreview = true
+				if m.width > 0 && m.height > 0 {
+					return m.Update(tea.WindowSizeMsg{Width: m.width, Height: m.height})
+				}
+			}
+			m.updatePreview()
+			return m, nil
+
+		case "v":
+			// View the selected note in the read-only viewer
+			if item, ok := m.list.SelectedItem().(noteInfo); ok && !item.isDir {
+				m.selected = &item
+				m.action = "view"
+				m.quitting = true
+				return m, screenDone
+			}
+
+		case "r":
+			// Switch the preview between rendered Markdown and raw text
+			config.Markdown = !config.Markdown
+			m.updatePreview()
+			if err := saveConfigValue("markdown", strconv.FormatBool(config.Markdown)); err != nil {
+				return m, m.notify("Could not save the setting: " + err.Error())
+			}
 			return m, nil
 
 		case "p":
-			// Toggle preview
+			// Toggle preview (and remember it for next time)
 			m.showPreview = !m.showPreview
+			config.Preview = m.showPreview
+			var cmd tea.Cmd
+			if err := saveConfigValue("preview", strconv.FormatBool(m.showPreview)); err != nil {
+				cmd = m.notify("Could not save the setting: " + err.Error())
+			}
 			// Force resize to recalculate layout
 			if m.width > 0 && m.height > 0 {
-				return m.Update(tea.WindowSizeMsg{Width: m.width, Height: m.height})
+				model, resize := m.Update(tea.WindowSizeMsg{Width: m.width, Height: m.height})
+				return model, tea.Batch(cmd, resize)
 			}
-			return m, nil
+			return m, cmd
 		}
 
 	case tea.WindowSizeMsg:
@@ -1301,14 +2089,7 @@ func (m noteListModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.viewport.Height = msg.Height - v - 3 // -3 for header
 
 			// Update preview content
-			if item, ok := m.list.SelectedItem().(noteInfo); ok {
-				content, err := os.ReadFile(filepath.Join(m.notesDir, item.name))
-				if err == nil {
-					m.viewport.SetContent(string(content))
-				} else {
-					m.viewport.SetContent(theme.Error.Render("Error reading file"))
-				}
-			}
+			m.updatePreview()
 		} else {
 			m.list.SetSize(msg.Width-h, msg.Height-v)
 		}
@@ -1320,14 +2101,7 @@ func (m noteListModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	m.list, cmd = m.list.Update(msg)
 
 	// If preview is shown and selection changed, update preview
-	if m.showPreview {
-		if item, ok := m.list.SelectedItem().(noteInfo); ok {
-			content, err := os.ReadFile(filepath.Join(m.notesDir, item.name))
-			if err == nil {
-				m.viewport.SetContent(string(content))
-			}
-		}
-	}
+	m.updatePreview()
 
 	return m, cmd
 }
@@ -1345,9 +2119,18 @@ func (m noteListModel) View() string {
 		notificationBar = theme.Success.Render("✓ " + m.notification) + "\n"
 	}
 
+	// Sync conflicts stay visible until every note is resolved
+	if len(m.conflicts) > 0 {
+		notificationBar += theme.Warning.Render(fmt.Sprintf("⚠ Sync conflicts in %s - edit to remove the <<<<<<< markers",
+			strings.Join(m.conflicts, ", "))) + "\n"
+	}
+
 	if m.showPreview {
 		// Split view: list on left, preview on right
 		previewHeader := theme.Header.Render(" Preview ")
+		if m.showHistory {
+			previewHeader = theme.Header.Render(" History ")
+		}
 		previewContent := m.viewport.View()
 		previewPanel := lipgloss.JoinVertical(lipgloss.Left, previewHeader, previewContent)
 
@@ -1411,6 +2194,7 @@ type menuModel struct {
 	cursor   int
 	selected string
 	quitting bool
+	status   string // error shown below the menu until the next key press
 	width    int
 	height   int
 }
@@ -1420,6 +2204,8 @@ func newMenuModel() menuModel {
 		choices: []string{
 			"Notes",
 			"New Note",
+			"Journal",
+			"Trash",
 			"Themes",
 			"Quit",
 		},
@@ -1440,11 +2226,12 @@ func (m menuModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.height = msg.Height
 
 	case tea.KeyMsg:
+		m.status = ""
 		switch msg.String() {
 		case "ctrl+c", "q":
 			m.quitting = true
 			m.selected = "quit"
-			return m, tea.Quit
+			return m, screenDone
 
 		case "up", "k":
 			if m.cursor > 0 {
@@ -1459,7 +2246,7 @@ func (m menuModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "enter":
 			m.selected = m.choices[m.cursor]
 			m.quitting = true
-			return m, tea.Quit
+			return m, screenDone
 		}
 	}
 	return m, nil
@@ -1485,6 +2272,9 @@ func (m menuModel) View() string {
 
 	// Footer
 	footer := "\n" + theme.Muted.Render("↑/↓: navigate • enter: select • q: quit")
+	if m.status != "" {
+		footer += "\n\n" + theme.Error.Render("✗ "+m.status)
+	}
 
 	// Build content
 	content := menuItems.String() + footer
@@ -1554,7 +2344,7 @@ func (m themeSelectModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch msg.String() {
 		case "ctrl+c", "q", "esc":
 			m.quitting = true
-			return m, tea.Quit
+			return m, screenDone
 
 		case "up", "k":
 			if m.cursor > 0 {
@@ -1569,7 +2359,7 @@ func (m themeSelectModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "enter":
 			m.selected = m.themeNames[m.cursor]
 			m.quitting = true
-			return m, tea.Quit
+			return m, screenDone
 		}
 	}
 	return m, nil
@@ -1632,60 +2422,38 @@ func (m themeSelectModel) View() string {
 	return style.Render(content)
 }
 
-// runThemeSelector launches the theme selection UI
-func runThemeSelector() {
-	m := newThemeSelectModel()
-	p := tea.NewProgram(m, tea.WithAltScreen())
-	result, err := p.Run()
-
-	if err != nil {
-		fmt.Println(theme.Error.Render("✗ Error: " + err.Error()))
-		return
+// applyTheme switches the global theme by name, returning false if it doesn't exist
+func applyTheme(name string) bool {
+	themeFn, ok := themes[name]
+	if !ok {
+		return false
 	}
 
-	themeSelect := result.(themeSelectModel)
+	theme = themeFn()
+	currentThemeName = name
 
-	if themeSelect.selected != "" {
-		// Apply the selected theme
-		if themeFn, ok := themes[themeSelect.selected]; ok {
-			theme = themeFn()
-			currentThemeName = themeSelect.selected
-
-			// Update legacy style aliases
-			selectedStyle = theme.Selected
-			unselectedStyle = theme.Unselected
-			boxStyle = theme.Border
+	// Update legacy style aliases
+	selectedStyle = theme.Selected
+	unselectedStyle = theme.Unselected
+	boxStyle = theme.Border
 
-			// Theme applied silently, return to menu
-		}
-	}
+	return true
 }
 
 // writeNote writes a note to a file
 func writeNote(filename, note string) {
 	// Validate filename first
-	if err := validateFilename(filename); err != nil {
-		fmt.Printf("Invalid filename: %v\n", err)
-		os.Exit(1)
-	}
-
-	notesDir, err := getNotesDir()
-	if err != nil {
-		fmt.Printf("Error getting notes directory: %v\n", err)
-		os.Exit(1)
-	}
-
-	// Build the full file path
-	filePath := filepath.Join(notesDir, filename)
+	checkName(filename)
+	notesDir := cliNotesDir()
 
-	// Write the note to the file
-	err = os.WriteFile(filePath, []byte(note), 0644)
+	// Encrypt the note if it already is, or --encrypt was given
+	data, err := sealForWrite(notesDir, filename, []byte(note))
 	if err != nil {
-		fmt.Println(theme.Error.Render("✗ Error writing file: " + err.Error()))
-		os.Exit(1)
+		fail(exitFailure, "Could not encrypt the note: %v", err)
 	}
 
-	fmt.Println(theme.Success.Render("✓ Successfully wrote note to " + filePath))
+	checkSaved(saveNote(notesDir, filename, data, "Write "+filename), "Could not write '%s'", filename)
+	fmt.Println(theme.Success.Render("✓ Successfully wrote note to " + notePath(notesDir, filename)))
 }
 
 // writeNoteQuiet writes a note without terminal output (for TUI use)
@@ -1700,116 +2468,186 @@ func writeNoteQuiet(filename, note string) error {
 		return err
 	}
 
-	// Build the full file path
-	filePath := filepath.Join(notesDir, filename)
+	// An encrypted note stays encrypted, with the passphrase it was unlocked with
+	data := []byte(note)
+	if isEncryptedNote(notesDir, filename) {
+		passphrase, ok := unlockedNotes[filename]
+		if !ok {
+			return errLocked
+		}
+		if data, err = encryptNote(data, passphrase); err != nil {
+			return err
+		}
+	}
 
-	// Write the note to the file
-	return os.WriteFile(filePath, []byte(note), 0644)
+	return saveNote(notesDir, filename, data, "Write "+filename)
 }
 
 // appendNote appends content to an existing note (or creates it if it doesn't exist)
 func appendNote(filename, note string) {
 	// Validate filename first
-	if err := validateFilename(filename); err != nil {
-		fmt.Printf("Invalid filename: %v\n", err)
-		os.Exit(1)
-	}
-
-	notesDir, err := getNotesDir()
-	if err != nil {
-		fmt.Printf("Error getting notes directory: %v\n", err)
-		os.Exit(1)
-	}
+	checkName(filename)
+	notesDir := cliNotesDir()
 
 	// Build the full file path
-	filePath := filepath.Join(notesDir, filename)
+	filePath := notePath(notesDir, filename)
 
-	// Check if file exists and get its size
-	fileInfo, err := os.Stat(filePath)
-	needsNewline := false
+	// Read the current content - appending rewrites the whole note atomically
+	existing, err := os.ReadFile(filePath)
 
 	// If file doesn't exist, ask for confirmation to create it
 	if os.IsNotExist(err) {
 		if !confirm(fmt.Sprintf("File '%s' does not exist. Create it?", filename)) {
-			fmt.Println("Append cancelled.")
-			return
-		}
-		// User confirmed, proceed with creation (needsNewline stays false for new files)
-	} else if err == nil && fileInfo.Size() > 0 {
-		// File exists and has content - check if it ends with newline
-		// Read the last byte to check if it's a newline
-		file, err := os.Open(filePath)
-		if err != nil {
-			// If we can't open to check, assume we need a newline to be safe
-			needsNewline = true
-		} else {
-			defer file.Close()
-			// Seek to the last byte
-			_, err = file.Seek(-1, io.SeekEnd)
-			if err != nil {
-				// If we can't seek, assume we need a newline
-				needsNewline = true
-			} else {
-				lastByte := make([]byte, 1)
-				_, err = file.Read(lastByte)
-				if err != nil {
-					// If we can't read, assume we need a newline
-					needsNewline = true
-				} else if lastByte[0] != '\n' {
-					// Last byte is not a newline, we need to add one
-					needsNewline = true
-				}
-			}
+			fail(exitCancelled, "Append cancelled")
 		}
+	} else if err != nil {
+		failErr(err, "Could not read '%s'", filename)
 	}
 
-	// Open file with append mode, create if doesn't exist, write-only
-	// O_APPEND: Append to end of file
-	// O_CREATE: Create file if it doesn't exist
-	// O_WRONLY: Write-only access
-	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		fmt.Printf("Error opening file: %v\n", err)
-		os.Exit(1)
+	// Encrypted notes are decrypted, appended to and encrypted again
+	passphrase := ""
+	if isEncrypted(existing) {
+		passphrase, existing = askPassphrase("Unlock "+filename, existing)
 	}
-	// defer ensures file is closed when function exits (even if there's an error)
-	defer file.Close()
 
 	// If the file doesn't end with newline, add one before appending
-	if needsNewline {
-		_, err = file.WriteString("\n")
-		if err != nil {
-			fmt.Printf("Error adding newline: %v\n", err)
-			os.Exit(1)
+	content := existing
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		content = append(content, '\n')
+	}
+	content = append(content, note...)
+	if passphrase != "" {
+		if content, err = encryptNote(content, passphrase); err != nil {
+			fail(exitFailure, "Could not encrypt the note: %v", err)
 		}
 	}
 
-	// Write the note to the file
-	_, err = file.WriteString(note)
-	if err != nil {
-		fmt.Println(theme.Error.Render("✗ Error appending to file: " + err.Error()))
-		os.Exit(1)
-	}
-
+	// Write the whole note back so a failed append can't leave it half-written
+	checkSaved(saveNote(notesDir, filename, content, "Append to "+filename), "Could not append to '%s'", filename)
 	fmt.Println(theme.Success.Render("✓ Successfully appended to " + filePath))
 }
 
-// noteInfo holds information about a note file for sorting
+// noteInfo holds information about a note file (or notebook folder) for sorting
 type noteInfo struct {
-	name    string
+	name    string // path relative to the notes directory, e.g. "work/standup.md"
 	modTime time.Time
 	size    int64
+	isDir   bool
+	meta    noteMeta // parsed from the note's front matter
 }
 
 // Implement list.Item interface for noteInfo
-func (n noteInfo) FilterValue() string { return n.name }
-func (n noteInfo) Title() string       { return n.name }
+func (n noteInfo) FilterValue() string {
+	if len(n.meta.tags) > 0 {
+		return n.name + " " + formatTags(n.meta.tags)
+	}
+	return n.name
+}
+func (n noteInfo) Title() string {
+	if n.isDir {
+		return n.name + "/"
+	}
+	return n.name
+}
 func (n noteInfo) Description() string {
 	timeStr := n.modTime.Format("2006-01-02 15:04")
+	if n.isDir {
+		return fmt.Sprintf("folder • %s", timeStr)
+	}
 	sizeStr := formatSize(n.size)
+	if len(n.meta.tags) > 0 {
+		return fmt.Sprintf("%s • %s • %s", sizeStr, timeStr, formatTags(n.meta.tags))
+	}
 	return fmt.Sprintf("%s • %s", sizeStr, timeStr)
 }
 
+// loadNotes reads the notes and folders directly inside dir (relative to the notes directory)
+func loadNotes(notesDir, dir string) ([]noteInfo, error) {
+	entries, err := os.ReadDir(notePath(notesDir, dir))
+	if err != nil {
+		return nil, err
+	}
+
+	var notes []noteInfo
+	for _, entry := range entries {
+		// Skip hidden files and folders
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			// If we can't get info, skip this entry
+			continue
+		}
+
+		note := noteInfo{
+			name:    path.Join(dir, entry.Name()),
+			modTime: info.ModTime(),
+			size:    info.Size(),
+			isDir:   entry.IsDir(),
+		}
+		if !note.isDir {
+			note.meta = readFrontMatter(notePath(notesDir, note.name))
+		}
+		notes = append(notes, note)
+	}
+
+	return notes, nil
+}
+
+// walkNotes collects every note in the notes directory, including those in folders
+func walkNotes(notesDir string) ([]noteInfo, error) {
+	notes, err := walkNoteFiles(notesDir)
+	for i := range notes {
+		notes[i].meta = readFrontMatter(notePath(notesDir, notes[i].name))
+	}
+	return notes, err
+}
+
+// walkNoteFiles collects every note like walkNotes, but only stats the files (no front matter)
+func walkNoteFiles(notesDir string) ([]noteInfo, error) {
+	var notes []noteInfo
+	err := filepath.WalkDir(notesDir, func(filePath string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if filePath == notesDir {
+			return nil
+		}
+
+		// Skip hidden files and folders
+		if strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+
+		rel, err := filepath.Rel(notesDir, filePath)
+		if err != nil {
+			return nil
+		}
+
+		notes = append(notes, noteInfo{
+			name:    filepath.ToSlash(rel),
+			modTime: info.ModTime(),
+			size:    info.Size(),
+		})
+		return nil
+	})
+
+	return notes, err
+}
+
 // searchResult holds information about a search match
 type searchResult struct {
 	note          noteInfo
@@ -1823,258 +2661,132 @@ func (s searchResult) Description() string {
 	return fmt.Sprintf("Match in: %s", s.matchLocation)
 }
 
-// handleListAction processes actions returned from the note list
-// Returns true if we should reload the list, false if we should exit to menu
-// Also returns a notification message to display
-func handleListAction(m noteListModel) (bool, string) {
-	switch m.action {
-	case "open":
-		if m.selected != nil {
-			saved, message := editNote(m.selected.name)
-			if saved {
-				return true, message // Return to list with notification
-			}
-			return true, "" // Return to list without notification (cancelled)
-		}
-	case "create":
-		filename, content, ok := interactiveWrite()
-		if ok {
-			writeNoteQuiet(filename, content)
-			return true, fmt.Sprintf("Created '%s'", filename) // Return to list with notification
-		}
-		return true, "" // Return to list without notification (cancelled)
-	case "rename":
-		if m.selected != nil {
-			newName, ok := runInteractiveRenameQuiet(m.selected.name)
-			if ok {
-				return true, fmt.Sprintf("Renamed to '%s'", newName)
-			}
-			return true, "" // Cancelled
-		}
-	case "delete":
-		if m.selected != nil {
-			deleteNoteQuiet(m.selected.name)
-			return true, fmt.Sprintf("Deleted '%s'", m.selected.name)
-		}
-	case "quit":
-		return false, "" // Exit to menu
+// renameNoteQuiet renames a note (or moves it to another folder) without terminal output (for TUI use)
+// An existing note with the new name is only replaced (moved to the trash) if replace is set;
+// otherwise an error matching fs.ErrExist is returned.
+// [[Links]] to the note are updated; the notes changed for that are returned, with
+// a *linkError (the rename still done) if some couldn't be.
+func renameNoteQuiet(oldName, newName string, replace bool) ([]string, error) {
+	if err := validateFilename(newName); err != nil {
+		return nil, err
 	}
-	return false, ""
-}
 
-// runInteractiveRenameQuiet renames a note and returns the new name and success status
-func runInteractiveRenameQuiet(oldName string) (string, bool) {
-	ti := textinput.New()
-	ti.Placeholder = oldName
-	ti.SetValue(oldName)
-	ti.Focus()
+	notesDir, err := getNotesDir()
+	if err != nil {
+		return nil, err
+	}
 
-	// For now, use a simple TUI text input (could be enhanced later)
-	fmt.Print(theme.Primary.Render("New filename: "))
+	linking := linksTo(notesDir, oldName)
+	if err := moveNote(notesDir, oldName, newName, replace); err != nil {
+		return nil, err
+	}
+	updateIndex(notesDir, oldName, newName)
+	relinked, err := updateLinks(notesDir, oldName, newName, linking)
+	gitCommit(notesDir, "Rename "+oldName+" to "+newName)
+	return relinked, err
+}
 
-	var newName string
-	fmt.Scanln(&newName)
+// moveNote renames a note on disk and takes its history along (the caller updates the index)
+func moveNote(notesDir, oldName, newName string, replace bool) error {
+	oldPath := notePath(notesDir, oldName)
+	newPath := notePath(notesDir, newName)
 
-	if newName != "" && newName != oldName {
-		if err := validateFilename(newName); err != nil {
-			return "", false
+	// Another note already has the name (a case-only rename finds the note itself)
+	if existing, err := os.Stat(newPath); err == nil {
+		if current, err := os.Stat(oldPath); err != nil || !os.SameFile(current, existing) {
+			if !replace {
+				return &fs.PathError{Op: "rename", Path: newName, Err: fs.ErrExist}
+			}
+			if err := moveToTrash(notesDir, newName); err != nil {
+				return err
+			}
 		}
+	}
 
-		notesDir, _ := getNotesDir()
-		oldPath := filepath.Join(notesDir, oldName)
-		newPath := filepath.Join(notesDir, newName)
-
-		if err := os.Rename(oldPath, newPath); err != nil {
-			return "", false
-		}
-		return newName, true
+	// Renaming can move a note into another folder
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return err
 	}
-	return "", false
-}
 
-// runInteractiveRename prompts for a new filename and renames the note (CLI version)
-func runInteractiveRename(oldName string) {
-	newName, ok := runInteractiveRenameQuiet(oldName)
-	if ok {
-		fmt.Println(theme.Success.Render("✓ Renamed to " + newName))
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return err
 	}
+	moveHistory(notesDir, oldName, newName)
+	return nil
 }
 
-// sortNotes sorts a slice of noteInfo by the specified mode
+// sortNotes sorts a slice of noteInfo by the specified mode (folders always come first)
 func sortNotes(notes []noteInfo, sortBy string) []noteInfo {
 	sorted := make([]noteInfo, len(notes))
 	copy(sorted, notes)
 
-	switch sortBy {
-	case "date":
-		sort.Slice(sorted, func(i, j int) bool {
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].isDir != sorted[j].isDir {
+			return sorted[i].isDir
+		}
+
+		switch sortBy {
+		case "date":
 			return sorted[i].modTime.After(sorted[j].modTime)
-		})
-	case "size":
-		sort.Slice(sorted, func(i, j int) bool {
+		case "size":
 			return sorted[i].size > sorted[j].size
-		})
-	default: // "name"
-		sort.Slice(sorted, func(i, j int) bool {
+		case "relevance":
+			return false // keep the search ranking
+		default: // "name"
 			return sorted[i].name < sorted[j].name
-		})
-	}
+		}
+	})
 
 	return sorted
 }
 
-// listNotesWithNotification lists notes starting with a notification
-func listNotesWithNotification(sortBy string, notification string) {
-	listNotesInternal(sortBy, true, notification)
-}
-
-// listNotes lists all notes in the notes directory with optional sorting
-func listNotes(sortBy string, interactive bool) {
-	listNotesInternal(sortBy, interactive, "")
+// listNotesByTag lists the notes carrying a tag (from their front matter)
+func listNotesByTag(sortBy string, tag string, interactive bool) {
+	listNotesInternal(sortBy, normalizeTag(tag), interactive, "")
 }
 
 // listNotesInternal is the internal implementation of list notes
-func listNotesInternal(sortBy string, interactive bool, initialNotification string) {
-	notesDir, err := getNotesDir()
-	if err != nil {
-		fmt.Printf("Error getting notes directory: %v\n", err)
-		os.Exit(1)
-	}
+func listNotesInternal(sortBy string, tag string, interactive bool, initialNotification string) {
+	notesDir := cliNotesDir()
 
-	// If interactive mode, launch TUI list with action loop
+	// If interactive mode, launch the TUI on the list
 	if interactive && isTTY() {
-		lastNotification := initialNotification
-		for {
-			// Read all entries in the notes directory
-			entries, err := os.ReadDir(notesDir)
-			if err != nil {
-				fmt.Printf("Error reading notes directory: %v\n", err)
-				os.Exit(1)
-			}
-
-			// Collect file information into a slice
-			var notes []noteInfo
-			for _, entry := range entries {
-				// Skip directories, only process files
-				if !entry.IsDir() {
-					info, err := entry.Info()
-					if err != nil {
-						// If we can't get info, skip this file
-						continue
-					}
-
-					notes = append(notes, noteInfo{
-						name:    entry.Name(),
-						modTime: info.ModTime(),
-						size:    info.Size(),
-					})
-				}
-			}
-
-			// Check if there are any notes
-			if len(notes) == 0 {
-				fmt.Println("No notes found.")
-				return
-			}
-
-			// Sort the notes
-			switch sortBy {
-			case "date":
-				sort.Slice(notes, func(i, j int) bool {
-					return notes[i].modTime.After(notes[j].modTime)
-				})
-			case "size":
-				sort.Slice(notes, func(i, j int) bool {
-					return notes[i].size > notes[j].size
-				})
-			default:
-				sort.Slice(notes, func(i, j int) bool {
-					return notes[i].name < notes[j].name
-				})
-			}
-
-			// Launch TUI list
-			m := newNoteListModel(notes, sortBy)
-			// Set notification if there is one from previous action
-			if lastNotification != "" {
-				m.notification = lastNotification
-				m.notificationTime = time.Now()
-				lastNotification = "" // Clear for next iteration
-			}
-			p := tea.NewProgram(m, tea.WithAltScreen())
-
-			finalModel, err := p.Run()
-			if err != nil {
-				fmt.Println(theme.Error.Render("✗ Error running list: " + err.Error()))
-				os.Exit(1)
-			}
-
-			// Handle actions from the list
-			if final, ok := finalModel.(noteListModel); ok {
-				shouldContinue, notification := handleListAction(final)
-				if !shouldContinue {
-					return // Exit to menu
-				}
-				// Store notification for next list instance
-				sortBy = final.sortMode // Preserve sort mode
-				lastNotification = notification
-				// Continue loop to reload list with notification
-			} else {
-				return
-			}
+		// Read the notes and folders at the top level, or every note with the tag
+		var notes []noteInfo
+		var err error
+		if tag != "" {
+			notes, err = walkNotes(notesDir)
+			notes = filterByTag(notes, tag)
+		} else {
+			notes, err = loadNotes(notesDir, "")
+		}
+		if err != nil {
+			failErr(err, "Could not read the notes directory")
 		}
-	}
-
-	// Non-interactive mode: simple list display
-	entries, err := os.ReadDir(notesDir)
-	if err != nil {
-		fmt.Printf("Error reading notes directory: %v\n", err)
-		os.Exit(1)
-	}
 
-	var notes []noteInfo
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			info, err := entry.Info()
-			if err != nil {
-				continue
-			}
-			notes = append(notes, noteInfo{
-				name:    entry.Name(),
-				modTime: info.ModTime(),
-				size:    info.Size(),
-			})
+		// Check if there are any notes
+		if len(notes) == 0 && tag != "" {
+			fmt.Printf("No notes tagged #%s.\n", tag)
+			return
+		}
+		if len(notes) == 0 {
+			fmt.Println("No notes found.")
+			return
 		}
-	}
 
-	if len(notes) == 0 {
-		fmt.Println("No notes found.")
-		return
-	}
+		m := newNoteListModel(sortNotes(notes, sortBy), sortBy)
+		m.tagFilter = tag
+		m.setDir("")
+		m.notification = initialNotification
 
-	switch sortBy {
-	case "date":
-		sort.Slice(notes, func(i, j int) bool {
-			return notes[i].modTime.After(notes[j].modTime)
-		})
-	case "size":
-		sort.Slice(notes, func(i, j int) bool {
-			return notes[i].size > notes[j].size
-		})
-	default:
-		sort.Slice(notes, func(i, j int) bool {
-			return notes[i].name < notes[j].name
-		})
+		app := newAppModel(listScreen)
+		app.setList(m)
+		runApp(app, tea.WithAltScreen())
+		return
 	}
 
-	fmt.Println(theme.Header.Render("Notes:"))
-	for _, note := range notes {
-		timeStr := note.modTime.Format("2006-01-02 15:04")
-		sizeStr := formatSize(note.size)
-		nameStyled := theme.Primary.Render(note.name)
-		metaStyled := theme.Secondary.Render(fmt.Sprintf("%8s  (modified: %s)", sizeStr, timeStr))
-		fmt.Printf("  • %s %s\n", nameStyled, metaStyled)
-	}
+	// Non-interactive mode: simple list display of every note, including folders
+	printNoteList(notesDir, listOptions{sortBy: sortBy, tag: tag})
 }
 
 // formatSize formats file size in human-readable format
@@ -2091,76 +2803,29 @@ func formatSize(bytes int64) string {
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
 
-// editNote opens a note for editing and returns whether it was saved and a message
-func editNote(filename string) (bool, string) {
-	// Validate filename first
-	if err := validateFilename(filename); err != nil {
-		return false, ""
-	}
-
-	notesDir, err := getNotesDir()
-	if err != nil {
-		return false, ""
-	}
-
-	// Build the full file path
-	filePath := filepath.Join(notesDir, filename)
-
-	// Read the file content
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return false, ""
-	}
-
-	// Launch interactive editor for editing the note
-	m := newNoteEditorModel(filename, string(content))
-	p := tea.NewProgram(m, tea.WithAltScreen())
-
-	result, err := p.Run()
-	if err != nil {
-		return false, ""
-	}
-
-	// Save if user pressed Ctrl+S
-	if editor, ok := result.(noteEditorModel); ok {
-		if editor.saved {
-			err = os.WriteFile(filePath, []byte(editor.content), 0644)
-			if err != nil {
-				return false, ""
-			}
-			return true, fmt.Sprintf("Saved changes to '%s'", filename)
-		}
-	}
-
-	return false, ""
-}
-
-// readNote opens a note for editing (CLI version with terminal output)
+// readNote shows a note in the read-only viewer (CLI version with terminal output)
 func readNote(filename string) {
 	// Validate filename first
-	if err := validateFilename(filename); err != nil {
-		fmt.Printf("Invalid filename: %v\n", err)
-		os.Exit(1)
-	}
-
-	notesDir, err := getNotesDir()
-	if err != nil {
-		fmt.Printf("Error getting notes directory: %v\n", err)
-		os.Exit(1)
-	}
+	checkName(filename)
+	notesDir := cliNotesDir()
 
 	// Build the full file path
-	filePath := filepath.Join(notesDir, filename)
+	filePath := notePath(notesDir, filename)
 
 	// Read the file content
 	content, err := os.ReadFile(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			fmt.Printf("Note '%s' not found.\n", filename)
-		} else {
-			fmt.Printf("Error reading file: %v\n", err)
+			fail(exitNotFound, "Note '%s' not found", filename)
 		}
-		os.Exit(1)
+		failErr(err, "Could not read '%s'", filename)
+	}
+
+	// Encrypted notes are unlocked with their passphrase first
+	if isEncrypted(content) {
+		var passphrase string
+		passphrase, content = askPassphrase("Unlock "+filename, content)
+		unlockedNotes[filename] = passphrase
 	}
 
 	// Check if we have a TTY - if not, fall back to simple print
@@ -2172,28 +2837,13 @@ func readNote(filename string) {
 		return
 	}
 
-	// Launch interactive editor for editing the note
-	m := newNoteEditorModel(filename, string(content))
-	p := tea.NewProgram(m, tea.WithAltScreen())
+	// Show the note in the viewer (e opens it for editing)
+	app := newAppModel(viewerScreen)
+	app.viewer = newNoteViewerModel(notesDir, filename, string(content))
+	app = runApp(app, tea.WithAltScreen())
 
-	result, err := p.Run()
-	if err != nil {
-		fmt.Println(theme.Error.Render("✗ Error running editor: " + err.Error()))
-		os.Exit(1)
-	}
-
-	// Save if user pressed Ctrl+S
-	if editor, ok := result.(noteEditorModel); ok {
-		if editor.saved {
-			err = os.WriteFile(filePath, []byte(editor.content), 0644)
-			if err != nil {
-				fmt.Println(theme.Error.Render("✗ Error saving file: " + err.Error()))
-			} else {
-				fmt.Println(theme.Success.Render("✓ Saved changes to " + filename))
-				fmt.Println(theme.Muted.Render("\nPress Enter to continue..."))
-				fmt.Scanln()
-			}
-		}
+	if app.saved {
+		fmt.Println(theme.Success.Render("✓ Saved changes to " + filename))
 	}
 }
 
@@ -2209,162 +2859,149 @@ func deleteNoteQuiet(filename string) error {
 		return err
 	}
 
-	// Build the full file path
-	filePath := filepath.Join(notesDir, filename)
+	// Move it to the trash so it can be restored
+	if err := moveToTrash(notesDir, filename); err != nil {
+		return err
+	}
 
-	// Delete the file
-	return os.Remove(filePath)
+	updateIndex(notesDir, filename)
+	gitCommit(notesDir, "Delete "+filename)
+	return nil
 }
 
 // deleteNote deletes a note (CLI version with terminal output)
 func deleteNote(filename string, force bool) {
 	// Validate filename first
-	if err := validateFilename(filename); err != nil {
-		fmt.Printf("Invalid filename: %v\n", err)
-		os.Exit(1)
-	}
-
-	notesDir, err := getNotesDir()
-	if err != nil {
-		fmt.Printf("Error getting notes directory: %v\n", err)
-		os.Exit(1)
-	}
+	checkName(filename)
+	notesDir := cliNotesDir()
 
 	// Build the full file path
-	filePath := filepath.Join(notesDir, filename)
+	filePath := notePath(notesDir, filename)
 
 	// Check if file exists before asking for confirmation
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		fmt.Printf("Note '%s' not found.\n", filename)
-		os.Exit(1)
+		fail(exitNotFound, "Note '%s' not found", filename)
 	}
 
 	// Ask for confirmation unless --force is used
 	if !force {
 		if !confirm(fmt.Sprintf("Delete '%s'?", filename)) {
-			fmt.Println("Deletion cancelled.")
-			return
+			fail(exitCancelled, "Deletion cancelled")
 		}
 	}
 
-	// Delete the file
-	err = os.Remove(filePath)
-	if err != nil {
+	// Move it to the trash so it can be restored
+	if err := moveToTrash(notesDir, filename); err != nil {
 		if os.IsNotExist(err) {
-			fmt.Println(theme.Error.Render("✗ Note '" + filename + "' not found"))
-		} else {
-			fmt.Println(theme.Error.Render("✗ Error deleting file: " + err.Error()))
+			fail(exitNotFound, "Note '%s' not found", filename)
 		}
-		os.Exit(1)
+		failErr(err, "Could not delete '%s'", filename)
 	}
 
-	fmt.Println(theme.Success.Render("✓ Successfully deleted note: " + filename))
+	updateIndex(notesDir, filename)
+	gitCommitCLI(notesDir, "Delete "+filename)
+	fmt.Println(theme.Success.Render("✓ Moved note to trash: " + filename))
+	fmt.Println(theme.Muted.Render("  Restore it with: ks trash restore " + filename))
 }
 
-// searchNotes searches for a keyword in all notes (filenames and content)
-func searchNotes(keyword string, interactive bool) {
-	notesDir, err := getNotesDir()
-	if err != nil {
-		fmt.Printf("Error getting notes directory: %v\n", err)
-		os.Exit(1)
-	}
+// searchNotes searches all notes (filenames, content and tags) for a query, best matches first
+// On a TTY results open in the interactive list; otherwise they print like grep (file:line:snippet)
+func searchNotes(query searchQuery, interactive bool) {
+	notesDir := cliNotesDir()
 
-	// Read all entries in the notes directory
-	entries, err := os.ReadDir(notesDir)
+	// Use the search index to find and rank matching notes
+	results, contents, err := indexedSearch(notesDir, query)
 	if err != nil {
-		fmt.Printf("Error reading notes directory: %v\n", err)
-		os.Exit(1)
-	}
-
-	// Convert keyword to lowercase for case-insensitive search
-	keywordLower := strings.ToLower(keyword)
-	var results []searchResult
-
-	// Search through each file
-	for _, entry := range entries {
-		// Skip directories, only process files
-		if !entry.IsDir() {
-			filePath := filepath.Join(notesDir, entry.Name())
-			filenameLower := strings.ToLower(entry.Name())
-
-			// Check if filename matches
-			filenameMatch := strings.Contains(filenameLower, keywordLower)
-
-			// Read the file content
-			content, err := os.ReadFile(filePath)
-			contentMatch := false
-
-			// Check content if we can read the file
-			if err == nil {
-				contentLower := strings.ToLower(string(content))
-				contentMatch = strings.Contains(contentLower, keywordLower)
-			}
-
-			// If either filename or content matches, add to results
-			if filenameMatch || contentMatch {
-				info, _ := entry.Info()
-				matchLocation := "content"
-				if filenameMatch && contentMatch {
-					matchLocation = "filename and content"
-				} else if filenameMatch {
-					matchLocation = "filename"
-				}
-
-				results = append(results, searchResult{
-					note: noteInfo{
-						name:    entry.Name(),
-						modTime: info.ModTime(),
-						size:    info.Size(),
-					},
-					matchLocation: matchLocation,
-				})
-			}
-		}
-	}
-
-	// Check if any results found
-	if len(results) == 0 {
-		fmt.Println(theme.Primary.Render("Searching for: ") + theme.Accent.Render(keyword))
-		fmt.Println()
-		fmt.Println(theme.Warning.Render("No matches found."))
-		return
+		failErr(err, "Could not read the notes directory")
 	}
 
 	// If interactive mode and TTY available, show interactive list
 	if interactive && isTTY() {
+		// Check if any results found
+		if len(results) == 0 {
+			fmt.Println(theme.Primary.Render("Searching for: ") + theme.Accent.Render(query.raw))
+			fmt.Println()
+			fmt.Println(theme.Warning.Render("No matches found."))
+			return
+		}
+
 		// Convert search results to notes for the list model
 		notes := make([]noteInfo, len(results))
 		for i, result := range results {
 			notes[i] = result.note
 		}
 
-		m := newNoteListModel(notes, "name")
-		m.list.Title = fmt.Sprintf("Search Results for: %s", keyword)
-
-		p := tea.NewProgram(m, tea.WithAltScreen())
-		finalModel, err := p.Run()
-		if err != nil {
-			fmt.Println(theme.Error.Render("✗ Error running search: " + err.Error()))
-			os.Exit(1)
-		}
+		m := newNoteListModel(notes, "relevance")
+		m.search = &query
+		m.setDir("")
 
-		// Handle actions from the list
-		if final, ok := finalModel.(noteListModel); ok {
-			handleListAction(final)
-		}
+		app := newAppModel(listScreen)
+		app.setList(m)
+		runApp(app, tea.WithAltScreen())
 		return
 	}
 
-	// Non-interactive mode: simple list display
-	fmt.Println(theme.Primary.Render("Searching for: ") + theme.Accent.Render(keyword))
-	fmt.Println()
+	// Non-interactive mode: grep-style output, exit status 1 when nothing matched
+	if len(results) == 0 {
+		os.Exit(exitFailure)
+	}
 
 	for _, result := range results {
-		matchLocation := theme.Accent.Render(result.matchLocation)
-		nameStyled := theme.Primary.Render(result.note.name)
-		fmt.Printf("  • %s %s\n", nameStyled, theme.Secondary.Render("(match in: ")+matchLocation+theme.Secondary.Render(")"))
+		lines := query.matchingLines(contents[result.note.name])
+		if len(lines) == 0 {
+			// Matched by filename or tag only
+			fmt.Println(result.note.name)
+			continue
+		}
+		for _, line := range lines {
+			fmt.Printf("%s:%d:%s\n", result.note.name, line.number, line.snippet)
+		}
+	}
+}
+
+// runSearch parses the query arguments and runs a search, exiting on invalid queries
+func runSearch(args []string, regex bool) {
+	query, err := parseQuery(args, regex)
+	if err != nil {
+		fail(exitUsage, "Invalid search: %v", err)
 	}
+	searchNotes(query, true)
+}
+
+// runSearchCommand handles "ks search [--regex] <query...>"
+func runSearchCommand(args []string) {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	var regexFlag bool
+	fs.BoolVar(&regexFlag, "E", false, "Treat terms as regular expressions")
+	fs.BoolVar(&regexFlag, "regex", false, "Treat terms as regular expressions")
+	fs.Usage = func() { printSearchUsage(os.Stderr) }
+	fs.Parse(args)
 
-	fmt.Println()
-	fmt.Println(theme.Success.Render(fmt.Sprintf("✓ Found %d match(es)", len(results))))
+	if fs.NArg() == 0 {
+		if isTTY() {
+			runInteractiveSearch()
+			return
+		}
+		usageError(printSearchUsage)
+	}
+
+	runSearch(fs.Args(), regexFlag)
+}
+
+// printSearchUsage displays the search query syntax
+func printSearchUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ks -s <query...>")
+	fmt.Fprintln(w, "   or: ks search [--regex] <query...>")
+	fmt.Fprintln(w, "\nQuery syntax (all parts must match):")
+	fmt.Fprintln(w, "  word                 Word in the filename, or starting a word in the content")
+	fmt.Fprintln(w, "  \"exact phrase\"       Phrase in the filename or content")
+	fmt.Fprintln(w, "  -word                Exclude notes containing word")
+	fmt.Fprintln(w, "  name:work/           Filename contains text")
+	fmt.Fprintln(w, "  tag:meeting          Note is tagged #meeting")
+	fmt.Fprintln(w, "  -E, --regex          Treat words and name: values as regular expressions")
+	fmt.Fprintln(w, "\nExamples:")
+	fmt.Fprintln(w, "  ks search deploy \"rollback plan\" -draft")
+	fmt.Fprintln(w, "  ks -s tag:meeting name:2024")
+	fmt.Fprintln(w, "  ks search --regex 'TODO|FIXME'")
+	fmt.Fprintln(w, "  ks -s -- -draft tag:infra       # use -- when the query starts with -")
 }
@@ -0,0 +1,529 @@
+package main
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Markdown block patterns
+var (
+	headingPattern   = regexp.MustCompile(`^ {0,3}(#{1,6})\s+(.*?)\s*#*\s*$`)
+	fencePattern     = regexp.MustCompile("^ {0,3}(```|~~~)\\s*(\\S*)")
+	listItemPattern  = regexp.MustCompile(`^(\s*)([-*+]|\d{1,9}[.)])\s+(.*)$`)
+	rulePattern      = regexp.MustCompile(`^ {0,3}((-\s*){3,}|(\*\s*){3,}|(_\s*){3,})$`)
+	tableSepPattern  = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	setextPattern    = regexp.MustCompile(`^ {0,3}(=+|-+)\s*$`)
+	quotePrefixRegex = regexp.MustCompile(`^ {0,3}> ?`)
+)
+
+// isMarkdown reports whether a note is rendered as Markdown (.md, .markdown or no extension)
+func isMarkdown(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".md", ".markdown", "":
+		return true
+	}
+	return false
+}
+
+// formatNote prepares a note for reading at the given width: Markdown notes are
+// rendered when config.Markdown is on, everything else is shown as written
+func formatNote(name, content string, width int) string {
+	if width <= 0 {
+		width = 80
+	}
+	if !config.Markdown || !isMarkdown(name) {
+		return ansi.Wrap(strings.ReplaceAll(content, "\t", "    "), width, "")
+	}
+	return renderMarkdown(content, width)
+}
+
+// renderMarkdown renders a note's Markdown for the terminal, styled with the current theme
+// The front matter is shown as a title and a line of tags.
+func renderMarkdown(content string, width int) string {
+	meta := parseFrontMatter(frontMatterLines(content))
+	body := stripFrontMatter(content)
+
+	var blocks []string
+	if meta.title != "" {
+		blocks = append(blocks, theme.Header.Render(meta.title))
+	}
+	if len(meta.tags) > 0 {
+		blocks = append(blocks, theme.Muted.Render(formatTags(meta.tags)))
+	}
+	if rendered := renderBlocks(strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n"), width); rendered != "" {
+		blocks = append(blocks, rendered)
+	}
+	return strings.Join(blocks, "\n\n") + "\n"
+}
+
+// renderBlocks renders Markdown lines as blocks separated by blank lines
+func renderBlocks(lines []string, width int) string {
+	var blocks []string
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+
+		case fencePattern.MatchString(line):
+			var block string
+			block, i = renderCodeBlock(lines, i, width)
+			blocks = append(blocks, block)
+
+		case headingPattern.MatchString(line):
+			match := headingPattern.FindStringSubmatch(line)
+			blocks = append(blocks, renderHeading(len(match[1]), match[2], width))
+			i++
+
+		case rulePattern.MatchString(line):
+			blocks = append(blocks, theme.Muted.Render(strings.Repeat("─", width)))
+			i++
+
+		case quotePrefixRegex.MatchString(line):
+			var quoted []string
+			for ; i < len(lines) && quotePrefixRegex.MatchString(lines[i]); i++ {
+				quoted = append(quoted, quotePrefixRegex.ReplaceAllString(lines[i], ""))
+			}
+			blocks = append(blocks, renderQuote(quoted, width))
+
+		case listItemPattern.MatchString(line):
+			var block string
+			block, i = renderList(lines, i, width)
+			blocks = append(blocks, block)
+
+		case i+1 < len(lines) && strings.Contains(line, "|") && tableSepPattern.MatchString(lines[i+1]):
+			var block string
+			block, i = renderTable(lines, i)
+			blocks = append(blocks, block)
+
+		default:
+			var block string
+			block, i = renderParagraph(lines, i, width)
+			blocks = append(blocks, block)
+		}
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// startsBlock reports whether a line begins a block other than a paragraph
+func startsBlock(line string) bool {
+	return fencePattern.MatchString(line) || headingPattern.MatchString(line) ||
+		rulePattern.MatchString(line) || quotePrefixRegex.MatchString(line) ||
+		listItemPattern.MatchString(line)
+}
+
+// renderHeading styles a heading by level
+func renderHeading(level int, text string, width int) string {
+	switch level {
+	case 1:
+		// Padding is added around the whole heading rather than every styled run in it
+		style := theme.Header.UnsetPadding()
+		return style.Render(" ") + renderInline(text, style) + style.Render(" ")
+	case 2:
+		title := renderInline(text, theme.Primary)
+		return title + "\n" + theme.Muted.Render(strings.Repeat("─", min(lipgloss.Width(title), width)))
+	case 3:
+		return renderInline(text, theme.Accent)
+	}
+	return renderInline(text, lipgloss.NewStyle().Bold(true).Foreground(theme.Secondary.GetForeground()))
+}
+
+// renderCodeBlock renders a fenced code block starting at lines[start]
+// Returns the block and the index of the line after it.
+func renderCodeBlock(lines []string, start, width int) (string, int) {
+	match := fencePattern.FindStringSubmatch(lines[start])
+	fence, lang := match[1], match[2]
+
+	bar := theme.Muted.Render("│ ")
+	code := lipgloss.NewStyle().Foreground(theme.Accent.GetForeground())
+
+	var out []string
+	if lang != "" {
+		out = append(out, theme.Muted.Render(lang))
+	}
+	i := start + 1
+	for ; i < len(lines); i++ {
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+			i++
+			break
+		}
+		text := ansi.Truncate(strings.ReplaceAll(lines[i], "\t", "    "), width-2, "…")
+		out = append(out, bar+code.Render(text))
+	}
+	return strings.Join(out, "\n"), i
+}
+
+// renderQuote renders a block quote with a bar down its left side
+func renderQuote(lines []string, width int) string {
+	inner := renderBlocks(lines, max(width-2, 10))
+	bar := lipgloss.NewStyle().Foreground(theme.Accent.GetForeground()).Render("│ ")
+	style := lipgloss.NewStyle().Italic(true).Foreground(theme.Secondary.GetForeground())
+
+	var out []string
+	for _, line := range strings.Split(inner, "\n") {
+		out = append(out, bar+style.Render(line))
+	}
+	return strings.Join(out, "\n")
+}
+
+// renderList renders consecutive list items (including nested ones) starting at lines[start]
+func renderList(lines []string, start, width int) (string, int) {
+	var out []string
+	i := start
+	for i < len(lines) {
+		match := listItemPattern.FindStringSubmatch(lines[i])
+		if match == nil {
+			break
+		}
+		indent := len(strings.ReplaceAll(match[1], "\t", "    ")) / 2
+		text := match[3]
+
+		// Lines continuing the item's text
+		for i++; i < len(lines); i++ {
+			next := lines[i]
+			if strings.TrimSpace(next) == "" || startsBlock(next) {
+				break
+			}
+			text += " " + strings.TrimSpace(next)
+		}
+
+		var marker string
+		textStyle := lipgloss.NewStyle()
+		switch {
+		case strings.HasPrefix(text, "[ ] "):
+			marker, text = theme.Accent.Render("☐"), text[4:]
+		case strings.HasPrefix(text, "[x] "), strings.HasPrefix(text, "[X] "):
+			marker, text = theme.Success.Render("☑"), text[4:]
+			textStyle = theme.Muted
+		case strings.HasSuffix(match[2], ".") || strings.HasSuffix(match[2], ")"):
+			marker = theme.Accent.Render(match[2])
+		default:
+			marker = theme.Accent.Render("•")
+		}
+
+		prefix := strings.Repeat("  ", indent) + marker + " "
+		hanging := strings.Repeat(" ", lipgloss.Width(prefix))
+		wrapped := ansi.Wrap(renderInline(text, textStyle), max(width-lipgloss.Width(prefix), 10), "")
+		for j, line := range strings.Split(wrapped, "\n") {
+			if j == 0 {
+				out = append(out, prefix+line)
+			} else {
+				out = append(out, hanging+line)
+			}
+		}
+
+		// A blank line between items doesn't end the list
+		if i+1 < len(lines) && strings.TrimSpace(lines[i]) == "" && listItemPattern.MatchString(lines[i+1]) {
+			i++
+		}
+	}
+	return strings.Join(out, "\n"), i
+}
+
+// renderTable renders a pipe table starting at lines[start] (header, separator, rows)
+func renderTable(lines []string, start int) (string, int) {
+	header := splitTableRow(lines[start])
+	aligns := make([]lipgloss.Position, len(header))
+	for col, cell := range splitTableRow(lines[start+1]) {
+		if col >= len(aligns) {
+			break
+		}
+		switch {
+		case strings.HasPrefix(cell, ":") && strings.HasSuffix(cell, ":"):
+			aligns[col] = lipgloss.Center
+		case strings.HasSuffix(cell, ":"):
+			aligns[col] = lipgloss.Right
+		default:
+			aligns[col] = lipgloss.Left
+		}
+	}
+
+	rows := [][]string{header}
+	i := start + 2
+	for ; i < len(lines) && strings.Contains(lines[i], "|") && strings.TrimSpace(lines[i]) != ""; i++ {
+		rows = append(rows, splitTableRow(lines[i]))
+	}
+
+	// Render the cells and measure the columns
+	widths := make([]int, len(header))
+	cells := make([][]string, len(rows))
+	for r, row := range rows {
+		style := lipgloss.NewStyle()
+		if r == 0 {
+			style = theme.Primary
+		}
+		cells[r] = make([]string, len(header))
+		for col := range header {
+			if col < len(row) {
+				cells[r][col] = renderInline(row[col], style)
+			}
+			widths[col] = max(widths[col], lipgloss.Width(cells[r][col]))
+		}
+	}
+
+	separator := theme.Muted.Render(" │ ")
+	var out []string
+	for r, row := range cells {
+		padded := make([]string, len(row))
+		for col, cell := range row {
+			padded[col] = lipgloss.PlaceHorizontal(widths[col], aligns[col], cell)
+		}
+		out = append(out, strings.Join(padded, separator))
+
+		if r == 0 {
+			rule := make([]string, len(widths))
+			for col, w := range widths {
+				rule[col] = strings.Repeat("─", w)
+			}
+			out = append(out, theme.Muted.Render(strings.Join(rule, "─┼─")))
+		}
+	}
+	return strings.Join(out, "\n"), i
+}
+
+// splitTableRow splits "| a | b |" into its trimmed cells, keeping escaped pipes
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// renderParagraph joins the lines of a paragraph and wraps it to width
+// A paragraph underlined with === or --- is a heading.
+func renderParagraph(lines []string, start, width int) (string, int) {
+	var text []string
+	i := start
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" || (i > start && startsBlock(line) && !setextPattern.MatchString(line)) {
+			break
+		}
+		if i > start && setextPattern.MatchString(line) {
+			level := 2
+			if strings.HasPrefix(strings.TrimSpace(line), "=") {
+				level = 1
+			}
+			return renderHeading(level, strings.Join(text, " "), width), i + 1
+		}
+		text = append(text, strings.TrimSpace(line))
+	}
+	return ansi.Wrap(renderInline(strings.Join(text, " "), lipgloss.NewStyle()), width, ""), i
+}
+
+// inlineStyle is the emphasis in effect for a run of text
+type inlineStyle struct {
+	bold, italic, strike, code, link bool
+}
+
+// renderInline renders emphasis, code spans and links in a line of text
+func renderInline(text string, base lipgloss.Style) string {
+	var out strings.Builder
+	writeInline(&out, text, inlineStyle{}, base)
+	return out.String()
+}
+
+// writeInline parses text for inline Markdown and writes it styled to out
+func writeInline(out *strings.Builder, text string, style inlineStyle, base lipgloss.Style) {
+	var plain strings.Builder
+	flush := func() {
+		if plain.Len() > 0 {
+			out.WriteString(style.render(plain.String(), base))
+			plain.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		rest := text[i:]
+
+		switch {
+		case c == '\\' && i+1 < len(text) && strings.ContainsRune("\\`*_{}[]()#+-.!|~<>", rune(text[i+1])):
+			plain.WriteByte(text[i+1])
+			i++
+			continue
+
+		case c == '`':
+			if end := strings.IndexByte(text[i+1:], '`'); end >= 0 {
+				flush()
+				code := style
+				code.code = true
+				out.WriteString(code.render(text[i+1:i+1+end], base))
+				i += end + 1
+				continue
+			}
+
+		case strings.HasPrefix(rest, "**") || strings.HasPrefix(rest, "__") || strings.HasPrefix(rest, "~~"):
+			delim := rest[:2]
+			if end := strings.Index(text[i+2:], delim); end > 0 {
+				flush()
+				inner := style
+				if delim == "~~" {
+					inner.strike = true
+				} else {
+					inner.bold = true
+				}
+				writeInline(out, text[i+2:i+2+end], inner, base)
+				i += end + 3
+				continue
+			}
+
+		case (c == '*' || c == '_') && i+1 < len(text) && text[i+1] != ' ' && (c == '*' || i == 0 || !isWordByte(text[i-1])):
+			if end := closingEmphasis(text[i+1:], c); end > 0 {
+				flush()
+				inner := style
+				inner.italic = true
+				writeInline(out, text[i+1:i+1+end], inner, base)
+				i += end + 1
+				continue
+			}
+
+		case strings.HasPrefix(rest, "[[") && wikiLinkPattern.MatchString(rest):
+			loc := wikiLinkPattern.FindStringIndex(rest)
+			if links := parseLinks(rest[:loc[1]]); loc[0] == 0 && len(links) == 1 {
+				flush()
+				link := links[0]
+				label := link.label
+				if label == "" {
+					label = link.target
+				}
+				wiki := style
+				wiki.link = true
+				out.WriteString(wiki.render(label, base))
+				i += loc[1] - 1
+				continue
+			}
+
+		case c == '[' || (c == '!' && strings.HasPrefix(rest, "![")):
+			label, url, length, ok := parseLink(rest)
+			if ok {
+				flush()
+				if c == '!' {
+					out.WriteString(theme.Muted.Render("[image: " + label + "]"))
+				} else {
+					link := style
+					link.link = true
+					writeInline(out, label, link, base)
+					if url != "" && url != label {
+						out.WriteString(theme.Muted.Render(" (" + url + ")"))
+					}
+				}
+				i += length - 1
+				continue
+			}
+
+		case c == '<':
+			if end := strings.IndexByte(rest, '>'); end > 0 && strings.Contains(rest[:end], "://") && !strings.Contains(rest[:end], " ") {
+				flush()
+				link := style
+				link.link = true
+				out.WriteString(link.render(rest[1:end], base))
+				i += end
+				continue
+			}
+		}
+
+		plain.WriteByte(c)
+	}
+	flush()
+}
+
+// closingEmphasis finds the delimiter closing single * or _ emphasis
+func closingEmphasis(text string, delim byte) int {
+	for i := 1; i < len(text); i++ {
+		if text[i] != delim || text[i-1] == ' ' {
+			continue
+		}
+		if delim == '_' && i+1 < len(text) && isWordByte(text[i+1]) {
+			continue
+		}
+		// Skip the first half of a ** or __ pair
+		if i+1 < len(text) && text[i+1] == delim {
+			i++
+			continue
+		}
+		return i
+	}
+	return -1
+}
+
+// parseLink parses "[label](url)" or "![alt](url)" at the start of text
+func parseLink(text string) (label, url string, length int, ok bool) {
+	start := strings.IndexByte(text, '[')
+	if start < 0 {
+		return "", "", 0, false
+	}
+	// The ] matching the [ (labels may hold brackets) must be followed by (
+	closing, depth := -1, 0
+	for i := start; i < len(text) && closing < 0; i++ {
+		switch text[i] {
+		case '[':
+			depth++
+		case ']':
+			if depth--; depth == 0 {
+				closing = i
+			}
+		}
+	}
+	if closing < 0 || !strings.HasPrefix(text[closing:], "](") {
+		return "", "", 0, false
+	}
+	end := strings.IndexByte(text[closing:], ')')
+	if end < 0 {
+		return "", "", 0, false
+	}
+	label = text[start+1 : closing]
+	url = strings.TrimSpace(text[closing+2 : closing+end])
+	// Drop a link title: [label](url "title")
+	if space := strings.IndexByte(url, ' '); space >= 0 {
+		url = url[:space]
+	}
+	return label, url, closing + end + 1, true
+}
+
+// isWordByte reports whether b is an ASCII letter or digit
+func isWordByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9'
+}
+
+// render styles a run of text on top of the block's base style
+func (s inlineStyle) render(text string, base lipgloss.Style) string {
+	style := base
+	if s.bold {
+		style = style.Bold(true)
+	}
+	if s.italic {
+		style = style.Italic(true)
+	}
+	if s.strike {
+		style = style.Strikethrough(true)
+	}
+	if s.code {
+		style = style.Foreground(theme.Accent.GetForeground()).Background(theme.Highlight.GetBackground())
+	}
+	if s.link {
+		style = style.Foreground(theme.Primary.GetForeground()).Underline(true)
+	}
+	return style.Render(text)
+}
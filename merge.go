@@ -0,0 +1,115 @@
+package main
+
+import (
+	"crypto/sha256"
+	"os"
+	"slices"
+	"strings"
+)
+
+// fileVersion identifies the content of a file at a point in time, so the
+// editor can tell whether someone else changed a note while it was open
+type fileVersion struct {
+	size int64
+	hash [sha256.Size]byte
+}
+
+// readFileVersion reads a file together with its version
+func readFileVersion(filePath string) ([]byte, fileVersion, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fileVersion{}, err
+	}
+	return content, fileVersion{size: int64(len(content)), hash: sha256.Sum256(content)}, nil
+}
+
+// Conflict markers written by mergeText (the same ones git uses)
+const (
+	conflictStart  = "<<<<<<< your changes"
+	conflictMiddle = "======="
+	conflictEnd    = ">>>>>>> on disk"
+)
+
+// mergeText combines two edited versions of base line by line (a three-way merge).
+// Where only one side changed a part of the note that change is taken; where both
+// changed the same lines differently, both versions are kept between conflict markers.
+// Returns the merged text and the number of conflicts.
+func mergeText(base, ours, theirs string) (string, int) {
+	baseLines := splitLines(base)
+	ourLines := splitLines(ours)
+	theirLines := splitLines(theirs)
+
+	ourMatch := matchBase(diffLines(baseLines, ourLines), len(baseLines))
+	theirMatch := matchBase(diffLines(baseLines, theirLines), len(baseLines))
+
+	var merged []string
+	conflicts := 0
+	i, o, t := 0, 0, 0
+	for {
+		// The next base line both sides kept is a stable point; merge the chunk before it
+		k := i
+		for k < len(baseLines) && (ourMatch[k] < 0 || theirMatch[k] < 0) {
+			k++
+		}
+
+		oEnd, tEnd := len(ourLines), len(theirLines)
+		if k < len(baseLines) {
+			oEnd, tEnd = ourMatch[k], theirMatch[k]
+		}
+
+		baseChunk := baseLines[i:k]
+		ourChunk := ourLines[o:oEnd]
+		theirChunk := theirLines[t:tEnd]
+
+		switch {
+		case slices.Equal(ourChunk, baseChunk):
+			merged = append(merged, theirChunk...)
+		case slices.Equal(theirChunk, baseChunk), slices.Equal(ourChunk, theirChunk):
+			merged = append(merged, ourChunk...)
+		default:
+			merged = append(merged, conflictStart)
+			merged = append(merged, ourChunk...)
+			merged = append(merged, conflictMiddle)
+			merged = append(merged, theirChunk...)
+			merged = append(merged, conflictEnd)
+			conflicts++
+		}
+
+		if k == len(baseLines) {
+			break
+		}
+		merged = append(merged, baseLines[k])
+		i, o, t = k+1, oEnd+1, tEnd+1
+	}
+
+	// The final newline is merged like a line: a side that added or removed it wins
+	newline := strings.HasSuffix(ours, "\n")
+	if newline == strings.HasSuffix(base, "\n") {
+		newline = strings.HasSuffix(theirs, "\n")
+	}
+	result := strings.Join(merged, "\n")
+	if len(merged) > 0 && newline {
+		result += "\n"
+	}
+	return result, conflicts
+}
+
+// matchBase maps every base line to the line it was kept as in the other text (-1 if removed)
+func matchBase(ops []diffOp, baseLen int) []int {
+	match := make([]int, baseLen)
+	i, j := 0, 0
+	for _, op := range ops {
+		switch op.kind {
+		case ' ':
+			match[i] = j
+			i++
+			j++
+		case '-':
+			match[i] = -1
+			i++
+		case '+':
+			j++
+		}
+	}
+	return match
+}
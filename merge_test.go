@@ -0,0 +1,143 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMergeText(t *testing.T) {
+	tests := []struct {
+		name               string
+		base, ours, theirs string
+		want               string
+		conflicts          int
+	}{
+		{
+			name: "unchanged",
+			base: "a\nb\n", ours: "a\nb\n", theirs: "a\nb\n",
+			want: "a\nb\n",
+		},
+		{
+			name: "only theirs edits",
+			base: "a\nb\nc\n", ours: "a\nb\nc\n", theirs: "a\nB\nc\n",
+			want: "a\nB\nc\n",
+		},
+		{
+			name: "both edit different lines",
+			base: "a\nb\nc\n", ours: "A\nb\nc\n", theirs: "a\nb\nC\n",
+			want: "A\nb\nC\n",
+		},
+		{
+			name: "ours appends while theirs edits",
+			base: "a\nb\n", ours: "a\nb\nc\n", theirs: "A\nb\n",
+			want: "A\nb\nc\n",
+		},
+		{
+			// Changes touching the same place conflict, as in git
+			name: "theirs appends right after the line ours edits",
+			base: "a\nb\n", ours: "a\nB\n", theirs: "a\nb\nc\n",
+			want:      "a\n" + conflictStart + "\nB\n" + conflictMiddle + "\nb\nc\n" + conflictEnd + "\n",
+			conflicts: 1,
+		},
+		{
+			name: "both append the same at EOF",
+			base: "a\n", ours: "a\nx\n", theirs: "a\nx\n",
+			want: "a\nx\n",
+		},
+		{
+			name: "both append differently at EOF",
+			base: "a\n", ours: "a\nx\n", theirs: "a\ny\n",
+			want:      "a\n" + conflictStart + "\nx\n" + conflictMiddle + "\ny\n" + conflictEnd + "\n",
+			conflicts: 1,
+		},
+		{
+			name: "both edit the same line",
+			base: "a\nb\nc\n", ours: "a\nB\nc\n", theirs: "a\nbee\nc\n",
+			want:      "a\n" + conflictStart + "\nB\n" + conflictMiddle + "\nbee\n" + conflictEnd + "\nc\n",
+			conflicts: 1,
+		},
+		{
+			name: "ours deletes a line theirs left alone",
+			base: "a\nb\nc\n", ours: "a\nc\n", theirs: "a\nb\nc\n",
+			want: "a\nc\n",
+		},
+		{
+			name: "ours deletes a line theirs modified",
+			base: "a\nb\nc\n", ours: "a\nc\n", theirs: "a\nB\nc\n",
+			want:      "a\n" + conflictStart + "\n" + conflictMiddle + "\nB\n" + conflictEnd + "\nc\n",
+			conflicts: 1,
+		},
+		{
+			name: "theirs deletes a line ours modified",
+			base: "a\nb\nc\n", ours: "a\nB\nc\n", theirs: "a\nc\n",
+			want:      "a\n" + conflictStart + "\nB\n" + conflictMiddle + "\n" + conflictEnd + "\nc\n",
+			conflicts: 1,
+		},
+		{
+			name: "empty base, only theirs writes",
+			base: "", ours: "", theirs: "new\n",
+			want: "new\n",
+		},
+		{
+			name: "no trailing newline anywhere",
+			base: "a\nb", ours: "a\nb\nc", theirs: "A\nb",
+			want: "A\nb\nc",
+		},
+		{
+			name: "ours adds the trailing newline",
+			base: "a", ours: "a\n", theirs: "a",
+			want: "a\n",
+		},
+		{
+			name: "ours removes the trailing newline",
+			base: "a\n", ours: "a", theirs: "a\n",
+			want: "a",
+		},
+		{
+			name: "theirs removes the trailing newline while ours edits",
+			base: "a\nb\n", ours: "A\nb\n", theirs: "a\nb",
+			want: "A\nb",
+		},
+		{
+			name: "everything deleted on one side",
+			base: "a\n", ours: "", theirs: "a\n",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conflicts := mergeText(tt.base, tt.ours, tt.theirs)
+			if got != tt.want || conflicts != tt.conflicts {
+				t.Errorf("mergeText(%q, %q, %q) = %q, %d; want %q, %d",
+					tt.base, tt.ours, tt.theirs, got, conflicts, tt.want, tt.conflicts)
+			}
+		})
+	}
+}
+
+func TestMatchBase(t *testing.T) {
+	tests := []struct {
+		name       string
+		base, text string
+		want       []int
+	}{
+		{"unchanged", "a\nb\nc", "a\nb\nc", []int{0, 1, 2}},
+		{"line removed", "a\nb\nc", "a\nc", []int{0, -1, 1}},
+		{"line inserted", "a\nb", "a\nx\nb", []int{0, 2}},
+		{"appended", "a\nb", "a\nb\nc", []int{0, 1}},
+		{"line replaced", "a\nb\nc", "a\nB\nc", []int{0, -1, 2}},
+		{"everything removed", "a\nb", "", []int{-1, -1}},
+		{"empty base", "", "a", []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseLines := splitLines(tt.base)
+			got := matchBase(diffLines(baseLines, splitLines(tt.text)), len(baseLines))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("matchBase(%q -> %q) = %v, want %v", tt.base, tt.text, got, tt.want)
+			}
+		})
+	}
+}
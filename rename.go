@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// renameModel is the dialog for renaming a note from the list
+// The new name is checked as it is typed, and replacing an existing note needs confirming
+type renameModel struct {
+	input       textinput.Model
+	notesDir    string
+	oldName     string
+	newName     string // set once the note was renamed
+	relinked    int    // notes whose [[links]] were updated for the new name
+	linkProblem string // the notes whose links couldn't be updated, if any
+	problem     string // why the typed name can't be used ("" = it can)
+	suggestion  string // fixed-up name offered with Tab
+	exists      bool   // the typed name belongs to another note
+	confirming  bool   // asking whether to replace that note
+	err         string // error from the rename itself
+	width       int
+	height      int
+}
+
+func newRenameModel(notesDir, oldName string) renameModel {
+	ti := textinput.New()
+	ti.SetValue(oldName)
+	ti.CharLimit = 255
+	ti.Width = 50
+	ti.Focus()
+
+	m := renameModel{input: ti, notesDir: notesDir, oldName: oldName}
+	m.validate()
+	return m
+}
+
+// validate checks the typed name
+func (m *renameModel) validate() {
+	name := strings.TrimSpace(m.input.Value())
+	m.problem, m.suggestion, m.exists = "", "", false
+
+	if name == "" {
+		m.problem = "Filename cannot be empty"
+		return
+	}
+	if err := validateFilename(name); err != nil {
+		m.problem = err.Error()
+		m.suggestion = suggestFilename(name)
+		return
+	}
+	if name == m.oldName {
+		return
+	}
+
+	info, err := os.Stat(notePath(m.notesDir, name))
+	if err != nil {
+		return
+	}
+	if info.IsDir() {
+		m.problem = fmt.Sprintf("'%s' is a folder", name)
+		return
+	}
+	m.exists = true
+}
+
+// apply renames the note, replacing an existing one only if confirmed
+func (m renameModel) apply(replace bool) (tea.Model, tea.Cmd) {
+	newName := strings.TrimSpace(m.input.Value())
+	relinked, err := renameNoteQuiet(m.oldName, newName, replace)
+	var linkErr *linkError
+	if errors.As(err, &linkErr) {
+		m.linkProblem = linkErr.Error()
+	} else if err != nil {
+		m.confirming = false
+		if errors.Is(err, fs.ErrExist) {
+			// Created by someone else since the last check
+			m.validate()
+			return m, nil
+		}
+		m.err = err.Error()
+		return m, nil
+	}
+	m.newName = newName
+	m.relinked = len(relinked)
+	return m, screenDone
+}
+
+func (m renameModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m renameModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		return m, nil
+
+	case tea.KeyMsg:
+		// Replace the existing note?
+		if m.confirming {
+			switch msg.String() {
+			case "y", "Y":
+				return m.apply(true)
+			case "n", "N", "esc":
+				m.confirming = false
+			case "ctrl+c":
+				return m, screenDone
+			}
+			return m, nil
+		}
+
+		switch msg.String() {
+		case "esc", "ctrl+c":
+			return m, screenDone
+
+		case "tab":
+			// Use the suggested fix for an invalid name
+			if m.suggestion != "" {
+				m.input.SetValue(m.suggestion)
+				m.input.CursorEnd()
+				m.err = ""
+				m.validate()
+			}
+			return m, nil
+
+		case "enter":
+			newName := strings.TrimSpace(m.input.Value())
+			if newName == m.oldName {
+				return m, screenDone
+			}
+			if m.problem != "" {
+				return m, nil
+			}
+			if m.exists {
+				m.confirming = true
+				return m, nil
+			}
+			return m.apply(false)
+		}
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	if _, ok := msg.(tea.KeyMsg); ok {
+		m.err = ""
+		m.validate()
+	}
+	return m, cmd
+}
+
+func (m renameModel) View() string {
+	var content strings.Builder
+	content.WriteString(theme.Primary.Render("Rename '"+m.oldName+"' to:") + "\n\n")
+	content.WriteString(m.input.View() + "\n\n")
+
+	newName := strings.TrimSpace(m.input.Value())
+	switch {
+	case m.err != "":
+		content.WriteString(theme.Error.Render("✗ " + m.err))
+	case m.problem != "" && m.suggestion != "":
+		content.WriteString(theme.Error.Render("✗ "+m.problem) + "\n")
+		content.WriteString(theme.Muted.Render("Suggestion: " + m.suggestion + " (press Tab to use)"))
+	case m.problem != "":
+		content.WriteString(theme.Error.Render("✗ " + m.problem))
+	case m.confirming:
+		content.WriteString(theme.Warning.Render(fmt.Sprintf("Replace '%s'? (y/n)", newName)) + "\n")
+		content.WriteString(theme.Muted.Render("The existing note is moved to the trash"))
+	case m.exists:
+		content.WriteString(theme.Warning.Render(fmt.Sprintf("⚠ '%s' already exists", newName)))
+	case newName == m.oldName:
+		content.WriteString(theme.Muted.Render("Unchanged"))
+	default:
+		content.WriteString(theme.Success.Render("✓ " + newName))
+	}
+
+	footer := "Enter: rename • Esc: cancel"
+	if m.confirming {
+		footer = "y: replace • n: keep editing"
+	}
+	content.WriteString("\n\n" + theme.Muted.Render(footer))
+
+	borderColor := theme.Accent.GetForeground()
+	if m.confirming {
+		borderColor = theme.Warning.GetForeground()
+	}
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(borderColor).
+		Padding(1, 2).
+		Width(64)
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, boxStyle.Render(content.String()), lipgloss.WithWhitespaceChars(" "))
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// saveError describes a failed save. The original file is never touched until
+// the new content is safely on disk, so it is always left as it was.
+type saveError struct {
+	path  string
+	stage string
+	err   error
+}
+
+func (e *saveError) Error() string {
+	return fmt.Sprintf("%s: %v (%s was not changed)", e.stage, e.err, filepath.Base(e.path))
+}
+
+func (e *saveError) Unwrap() error {
+	return e.err
+}
+
+// saveFile writes data to a file atomically: the content goes to a temporary file
+// in the same folder, is synced to disk and then renamed over the original, so a
+// crash or a full disk leaves either the old or the new note - never a truncated one.
+// The original file's permissions are kept, and symlinks are written through.
+func saveFile(filePath string, data []byte) error {
+	if target, err := filepath.EvalSymlinks(filePath); err == nil {
+		filePath = target
+	}
+
+	mode := fs.FileMode(0644)
+	if info, err := os.Stat(filePath); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmpPath, err := writeTempFile(filePath, data, mode)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return &saveError{filePath, "replacing the file", err}
+	}
+
+	syncDir(filepath.Dir(filePath))
+	return nil
+}
+
+// commitError is returned by saveNote when the note was saved but the git
+// commit failed: the change is on disk, only the commit is missing
+type commitError struct {
+	err error
+}
+
+func (e *commitError) Error() string {
+	return "saved, but could not commit: " + e.err.Error()
+}
+
+func (e *commitError) Unwrap() error {
+	return e.err
+}
+
+// isCommitError reports whether a save only failed to commit
+func isCommitError(err error) bool {
+	var commitErr *commitError
+	return errors.As(err, &commitErr)
+}
+
+// saveNote is how a note's new content is saved: the old and new versions go
+// to the history, the file is replaced atomically (readable by its owner only
+// when encrypted), the index is updated and, with a commit message, the change
+// is committed. Several notes saved together pass "" and commit once themselves.
+// Encrypting a plain text note drops its history instead, since plain text
+// copies must not outlive the note.
+func saveNote(notesDir, name string, data []byte, commitMsg string) error {
+	filePath := notePath(notesDir, name)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+
+	encrypting := isEncrypted(data) && !isEncryptedNote(notesDir, name)
+	if !encrypting {
+		snapshotNote(notesDir, name)
+	}
+	if err := saveFile(filePath, data); err != nil {
+		return err
+	}
+	if isEncrypted(data) {
+		protectFile(filePath)
+	}
+	if encrypting {
+		os.RemoveAll(historyDir(notesDir, name))
+	}
+	snapshotNote(notesDir, name)
+	updateIndex(notesDir, name)
+
+	if commitMsg != "" {
+		if err := gitCommit(notesDir, commitMsg); err != nil {
+			return &commitError{err}
+		}
+	}
+	return nil
+}
+
+// saveNewFile is saveFile for a file that must not exist yet
+// Returns an error matching fs.ErrExist if it does, instead of overwriting it
+func saveNewFile(filePath string, data []byte) error {
+	tmpPath, err := writeTempFile(filePath, data, 0644)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+
+	// A hard link fails if the target exists, unlike rename
+	if err := os.Link(tmpPath, filePath); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return err
+		}
+		// Some filesystems can't hard link - check and rename instead
+		if _, statErr := os.Lstat(filePath); statErr == nil {
+			return &fs.PathError{Op: "create", Path: filePath, Err: fs.ErrExist}
+		}
+		if err := os.Rename(tmpPath, filePath); err != nil {
+			return &saveError{filePath, "creating the file", err}
+		}
+	}
+
+	syncDir(filepath.Dir(filePath))
+	return nil
+}
+
+// writeTempFile writes data to a synced temporary file next to filePath and returns its path
+func writeTempFile(filePath string, data []byte, mode fs.FileMode) (string, error) {
+	// Hidden, so a leftover from a crash never shows up as a note
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return "", &saveError{filePath, "creating a temporary file", err}
+	}
+	tmpPath := tmp.Name()
+
+	fail := func(stage string, err error) (string, error) {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return "", &saveError{filePath, stage, err}
+	}
+
+	n, err := tmp.Write(data)
+	if err != nil {
+		return fail(fmt.Sprintf("writing (only %d of %d bytes written)", n, len(data)), err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail("flushing to disk", err)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		return fail("setting permissions", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return "", &saveError{filePath, "closing the temporary file", err}
+	}
+
+	return tmpPath, nil
+}
+
+// syncDir flushes a folder so a rename into it survives a crash
+// Errors are ignored: not every platform can sync directories
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
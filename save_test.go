@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// assertContent fails the test unless the file holds want
+func assertContent(t *testing.T, filePath, want string) {
+	t.Helper()
+	got, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("%s = %q, want %q", filepath.Base(filePath), got, want)
+	}
+}
+
+// assertFiles fails the test unless dir holds exactly the files in want, so
+// no temporary file was left behind
+func assertFiles(t *testing.T, dir string, want ...string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if !slices.Equal(names, want) {
+		t.Errorf("%s holds %v, want %v", filepath.Base(dir), names, want)
+	}
+}
+
+func TestSaveFileCreatesAndReplaces(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "note.md")
+
+	if err := saveFile(filePath, []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, filePath, "first")
+	info, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("new file mode = %v, want 0644", info.Mode().Perm())
+	}
+
+	if err := saveFile(filePath, []byte("second")); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, filePath, "second")
+	assertFiles(t, dir, "note.md")
+}
+
+func TestSaveFileKeepsMode(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "secret.md")
+	if err := os.WriteFile(filePath, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filePath, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := saveFile(filePath, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+	assertContent(t, filePath, "new")
+}
+
+func TestSaveFileWritesThroughSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.md")
+	link := filepath.Join(dir, "link.md")
+	if err := os.WriteFile(target, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	if err := saveFile(link, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&fs.ModeSymlink == 0 {
+		t.Errorf("link.md was replaced by a regular file")
+	}
+	assertContent(t, target, "new")
+	assertFiles(t, dir, "link.md", "target.md")
+}
+
+func TestSaveFileFailureLeavesNoTempFile(t *testing.T) {
+	dir := t.TempDir()
+
+	// A folder that isn't empty can't be replaced by a file
+	filePath := filepath.Join(dir, "folder")
+	if err := os.MkdirAll(filepath.Join(filePath, "inside"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	err := saveFile(filePath, []byte("data"))
+	var saveErr *saveError
+	if !errors.As(err, &saveErr) {
+		t.Fatalf("saveFile over a folder = %v, want a *saveError", err)
+	}
+	assertFiles(t, dir, "folder")
+
+	// The temporary file can't even be created in a missing folder
+	missing := filepath.Join(dir, "missing", "note.md")
+	if err := saveFile(missing, []byte("data")); !errors.As(err, &saveErr) {
+		t.Fatalf("saveFile in a missing folder = %v, want a *saveError", err)
+	}
+	assertFiles(t, dir, "folder")
+}
+
+func TestSaveNewFile(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "note.md")
+
+	if err := saveNewFile(filePath, []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, filePath, "first")
+
+	err := saveNewFile(filePath, []byte("second"))
+	if !errors.Is(err, fs.ErrExist) {
+		t.Errorf("saveNewFile over an existing file = %v, want fs.ErrExist", err)
+	}
+	assertContent(t, filePath, "first")
+	assertFiles(t, dir, "note.md")
+}
+
+func TestSaveNewFileRefusesSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.md")
+	link := filepath.Join(dir, "link.md")
+	if err := os.WriteFile(target, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	if err := saveNewFile(link, []byte("new")); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("saveNewFile over a symlink = %v, want fs.ErrExist", err)
+	}
+	assertContent(t, target, "old")
+	assertFiles(t, dir, "link.md", "target.md")
+}
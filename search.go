@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// searchQuery is a parsed search expression. Every included term must match
+// the note's filename or content, excluded terms must not match either, and
+// name:/tag: qualifiers restrict the filename and front matter tags.
+//
+//	standup "release notes" -draft name:work/ tag:meeting
+type searchQuery struct {
+	raw          string
+	include      []searchTerm
+	exclude      []searchTerm
+	names        []searchTerm
+	excludeNames []searchTerm
+	tags         []string
+	excludeTags  []string
+	regex        bool
+}
+
+// searchTerm is a single word, phrase or regular expression from a query
+type searchTerm struct {
+	text string
+	re   *regexp.Regexp
+}
+
+// queryToken is a piece of the query string before it is interpreted
+type queryToken struct {
+	text   string
+	quoted bool // quoted phrases are never treated as exclusions or qualifiers
+}
+
+// parseQuery builds a searchQuery from command line arguments
+// In regex mode every term and name: qualifier is a (case-insensitive) regular expression
+func parseQuery(args []string, regex bool) (searchQuery, error) {
+	q := searchQuery{raw: strings.Join(args, " "), regex: regex}
+
+	for _, token := range tokenizeQuery(args) {
+		text := token.text
+		negate := false
+
+		if !token.quoted {
+			if strings.HasPrefix(text, "-") && len(text) > 1 {
+				negate = true
+				text = text[1:]
+			}
+
+			if value, ok := strings.CutPrefix(text, "tag:"); ok {
+				tag := normalizeTag(value)
+				if tag == "" {
+					return q, fmt.Errorf("tag: needs a value")
+				}
+				if negate {
+					q.excludeTags = append(q.excludeTags, tag)
+				} else {
+					q.tags = append(q.tags, tag)
+				}
+				continue
+			}
+
+			if value, ok := strings.CutPrefix(text, "name:"); ok {
+				term, err := newSearchTerm(value, regex)
+				if err != nil {
+					return q, err
+				}
+				if negate {
+					q.excludeNames = append(q.excludeNames, term)
+				} else {
+					q.names = append(q.names, term)
+				}
+				continue
+			}
+		}
+
+		term, err := newSearchTerm(text, regex)
+		if err != nil {
+			return q, err
+		}
+		if negate {
+			q.exclude = append(q.exclude, term)
+		} else {
+			q.include = append(q.include, term)
+		}
+	}
+
+	if len(q.include) == 0 && len(q.names) == 0 && len(q.tags) == 0 {
+		return q, fmt.Errorf("search query needs at least one term, name: or tag:")
+	}
+
+	return q, nil
+}
+
+// newSearchTerm compiles a term into a case-insensitive pattern (^ and $ match at line breaks)
+func newSearchTerm(text string, regex bool) (searchTerm, error) {
+	if text == "" {
+		return searchTerm{}, fmt.Errorf("empty search term")
+	}
+
+	pattern := regexp.QuoteMeta(text)
+	if regex {
+		pattern = text
+	}
+
+	re, err := regexp.Compile("(?im)" + pattern)
+	if err != nil {
+		return searchTerm{}, fmt.Errorf("invalid regular expression %q: %v", text, err)
+	}
+
+	return searchTerm{text: text, re: re}, nil
+}
+
+// tokenizeQuery splits arguments into words, keeping "quoted phrases" together
+// An argument that contains spaces but no quotes (already grouped by the shell) is a phrase
+func tokenizeQuery(args []string) []queryToken {
+	var tokens []queryToken
+
+	for _, arg := range args {
+		if strings.ContainsFunc(arg, unicode.IsSpace) && !strings.Contains(arg, `"`) {
+			tokens = append(tokens, queryToken{text: arg, quoted: true})
+			continue
+		}
+
+		var current strings.Builder
+		inQuotes, quoted, started := false, false, false
+		flush := func() {
+			if started {
+				tokens = append(tokens, queryToken{text: current.String(), quoted: quoted})
+			}
+			current.Reset()
+			inQuotes, quoted, started = false, false, false
+		}
+
+		for _, r := range arg {
+			switch {
+			case r == '"':
+				// A quote at the start of a token makes the whole token a phrase
+				if !started {
+					quoted = true
+				}
+				inQuotes = !inQuotes
+				started = true
+			case unicode.IsSpace(r) && !inQuotes:
+				flush()
+			default:
+				current.WriteRune(r)
+				started = true
+			}
+		}
+		flush()
+	}
+
+	// Drop tokens that ended up empty (e.g. "")
+	var result []queryToken
+	for _, token := range tokens {
+		if token.text != "" {
+			result = append(result, token)
+		}
+	}
+	return result
+}
+
+// needsContent reports whether the query has terms matched against the note contents
+func (q searchQuery) needsContent() bool {
+	return len(q.include) > 0 || len(q.exclude) > 0
+}
+
+// match reports whether a note satisfies the query and where it matched
+// ("filename", "content", "filename and content" or "tag")
+func (q searchQuery) match(note noteInfo, content string) (bool, string) {
+	if !q.matchesTags(note) {
+		return false, ""
+	}
+
+	nameMatch := false
+	for _, term := range q.names {
+		if !term.re.MatchString(note.name) {
+			return false, ""
+		}
+		nameMatch = true
+	}
+	for _, term := range q.excludeNames {
+		if term.re.MatchString(note.name) {
+			return false, ""
+		}
+	}
+
+	for _, term := range q.exclude {
+		if term.re.MatchString(note.name) || term.re.MatchString(content) {
+			return false, ""
+		}
+	}
+
+	contentMatch := false
+	for _, term := range q.include {
+		inName := term.re.MatchString(note.name)
+		inContent := term.re.MatchString(content)
+		if !inName && !inContent {
+			return false, ""
+		}
+		nameMatch = nameMatch || inName
+		contentMatch = contentMatch || inContent
+	}
+
+	switch {
+	case nameMatch && contentMatch:
+		return true, "filename and content"
+	case nameMatch:
+		return true, "filename"
+	case contentMatch:
+		return true, "content"
+	default:
+		return true, "tag"
+	}
+}
+
+// matchesTags reports whether a note satisfies the query's tag: qualifiers
+func (q searchQuery) matchesTags(note noteInfo) bool {
+	for _, tag := range q.tags {
+		if !note.meta.hasTag(tag) {
+			return false
+		}
+	}
+	for _, tag := range q.excludeTags {
+		if note.meta.hasTag(tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// searchLine is a line of a note that matched one of the query terms
+type searchLine struct {
+	number  int
+	snippet string
+}
+
+// matchingLines returns the lines of content that match any included term
+func (q searchQuery) matchingLines(content string) []searchLine {
+	var lines []searchLine
+	for i, line := range strings.Split(content, "\n") {
+		for _, term := range q.include {
+			if loc := term.re.FindStringIndex(line); loc != nil {
+				lines = append(lines, searchLine{number: i + 1, snippet: snippet(line, loc[0], loc[1])})
+				break
+			}
+		}
+	}
+	return lines
+}
+
+// snippet trims a long line to a window around the match at [start, end)
+func snippet(line string, start, end int) string {
+	const context = 50
+
+	line = strings.TrimRight(line, "\r")
+	if len(line) <= 2*context+(end-start) {
+		return strings.TrimSpace(line)
+	}
+
+	from := max(start-context, 0)
+	to := min(end+context, len(line))
+
+	// Don't cut a multi-byte character in half
+	for from > 0 && !utf8.RuneStart(line[from]) {
+		from--
+	}
+	for to < len(line) && !utf8.RuneStart(line[to]) {
+		to++
+	}
+
+	result := strings.TrimSpace(line[from:to])
+	if from > 0 {
+		result = "…" + result
+	}
+	if to < len(line) {
+		result += "…"
+	}
+	return result
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// termTexts returns the text of each search term
+func termTexts(terms []searchTerm) []string {
+	var texts []string
+	for _, term := range terms {
+		texts = append(texts, term.text)
+	}
+	return texts
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		regex        bool
+		include      []string
+		exclude      []string
+		names        []string
+		excludeNames []string
+		tags         []string
+		excludeTags  []string
+		wantErr      bool
+	}{
+		{
+			name:    "words",
+			args:    []string{"deploy", "staging"},
+			include: []string{"deploy", "staging"},
+		},
+		{
+			name:    "words in one argument",
+			args:    []string{"deploy -draft"},
+			include: []string{"deploy -draft"},
+		},
+		{
+			name:    "exclusion",
+			args:    []string{"deploy", "-draft"},
+			include: []string{"deploy"},
+			exclude: []string{"draft"},
+		},
+		{
+			name:    "quoted phrase",
+			args:    []string{`"release notes"`, "-draft"},
+			include: []string{"release notes"},
+			exclude: []string{"draft"},
+		},
+		{
+			name:    "quoted phrase inside an argument",
+			args:    []string{`standup "release notes" -draft`},
+			include: []string{"standup", "release notes"},
+			exclude: []string{"draft"},
+		},
+		{
+			name:    "quoted exclusion is a phrase",
+			args:    []string{`"-draft"`},
+			include: []string{"-draft"},
+		},
+		{
+			name:    "a lone dash is a term",
+			args:    []string{"-"},
+			include: []string{"-"},
+		},
+		{
+			name:         "name qualifiers",
+			args:         []string{"name:work/", "-name:old"},
+			names:        []string{"work/"},
+			excludeNames: []string{"old"},
+		},
+		{
+			name:        "tag qualifiers are normalised",
+			args:        []string{"tag:#Meeting", "-tag:draft", "notes"},
+			include:     []string{"notes"},
+			tags:        []string{"meeting"},
+			excludeTags: []string{"draft"},
+		},
+		{
+			name:    "quoted qualifier is a phrase",
+			args:    []string{`"tag:meeting"`},
+			include: []string{"tag:meeting"},
+		},
+		{
+			name:    "regular expressions",
+			args:    []string{`dep(loy|th)`, "name:^work/"},
+			regex:   true,
+			include: []string{`dep(loy|th)`},
+			names:   []string{"^work/"},
+		},
+		{
+			name:    "invalid regular expression",
+			args:    []string{"dep(loy"},
+			regex:   true,
+			wantErr: true,
+		},
+		{
+			name:    "special characters are literal without regex",
+			args:    []string{"dep(loy"},
+			include: []string{"dep(loy"},
+		},
+		{
+			name:    "only exclusions",
+			args:    []string{"-draft"},
+			wantErr: true,
+		},
+		{
+			name:    "empty tag",
+			args:    []string{"tag:", "notes"},
+			wantErr: true,
+		},
+		{
+			name:    "empty name",
+			args:    []string{"name:", "notes"},
+			wantErr: true,
+		},
+		{
+			name:    "empty quotes",
+			args:    []string{`""`},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := parseQuery(tt.args, tt.regex)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseQuery(%q) succeeded, want an error", tt.args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseQuery(%q): %v", tt.args, err)
+			}
+
+			for _, field := range []struct {
+				name      string
+				got, want []string
+			}{
+				{"include", termTexts(q.include), tt.include},
+				{"exclude", termTexts(q.exclude), tt.exclude},
+				{"names", termTexts(q.names), tt.names},
+				{"excludeNames", termTexts(q.excludeNames), tt.excludeNames},
+				{"tags", q.tags, tt.tags},
+				{"excludeTags", q.excludeTags, tt.excludeTags},
+			} {
+				if !slices.Equal(field.got, field.want) {
+					t.Errorf("parseQuery(%q) %s = %q, want %q", tt.args, field.name, field.got, field.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// builtinTemplates are offered until the templates folder has one of the same name
+var builtinTemplates = map[string]string{
+	"meeting": `# {{title}}
+
+Date: {{date}} {{time}}
+Attendees: {{attendees}}
+
+## Agenda
+
+-
+
+## Notes
+
+## Action items
+
+- [ ]
+`,
+	"postmortem": `# Incident postmortem: {{title}}
+
+Date: {{date}}
+Author: {{user}}
+Severity: {{severity}}
+
+## Summary
+
+## Impact
+
+## Timeline
+
+- {{time}}
+
+## Root cause
+
+## What went well
+
+## What went wrong
+
+## Action items
+
+- [ ]
+`,
+	"adr": `# ADR: {{title}}
+
+Date: {{date}}
+Status: {{status}}
+Deciders: {{user}}
+
+## Context
+
+## Decision
+
+## Consequences
+`,
+}
+
+// placeholderPattern matches template variables such as {{date}} or {{ attendees }}
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z][\w-]*)\s*\}\}`)
+
+// templatesDir returns the folder templates are kept in (templates_dir, relative to the notes directory)
+func templatesDir(notesDir string) (string, error) {
+	dir := config.TemplatesDir
+	if filepath.IsAbs(dir) || strings.HasPrefix(dir, "~") {
+		return expandPath(dir)
+	}
+	return notePath(notesDir, dir), nil
+}
+
+// listTemplates returns the names of the available templates, without the .md extension
+func listTemplates(notesDir string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	if dir, err := templatesDir(notesDir); err == nil {
+		filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if strings.HasPrefix(d.Name(), ".") && p != dir {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return nil
+			}
+			name := strings.TrimSuffix(filepath.ToSlash(rel), ".md")
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+			return nil
+		})
+	}
+	for name := range builtinTemplates {
+		if !seen[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readTemplate returns a template's text by name: a file in the templates
+// folder (with or without .md), a built-in template, or a note path
+func readTemplate(notesDir, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "~") {
+		filePath, err := expandPath(name)
+		if err != nil {
+			return "", err
+		}
+		content, err := os.ReadFile(filePath)
+		return string(content), err
+	}
+
+	if filePath, ok := templateFile(notesDir, name); ok {
+		content, err := os.ReadFile(filePath)
+		return string(content), err
+	}
+	if content, ok := builtinTemplates[strings.TrimSuffix(strings.ToLower(name), ".md")]; ok {
+		return content, nil
+	}
+	if validateFilename(name) == nil {
+		if content, err := os.ReadFile(notePath(notesDir, name)); err == nil {
+			return string(content), nil
+		}
+	}
+	return "", fmt.Errorf("no template named '%s'", name)
+}
+
+// templateFile finds a template in the templates folder, with or without .md
+func templateFile(notesDir, name string) (string, bool) {
+	dir, err := templatesDir(notesDir)
+	if err != nil || validateFilename(name) != nil {
+		return "", false
+	}
+	for _, candidate := range []string{name, name + ".md"} {
+		filePath := filepath.Join(dir, filepath.FromSlash(candidate))
+		if info, err := os.Stat(filePath); err == nil && !info.IsDir() {
+			return filePath, true
+		}
+	}
+	return "", false
+}
+
+// templateVars returns the values of the built-in template variables for a new note
+func templateVars(filename string, now time.Time) map[string]string {
+	return map[string]string{
+		"date":  now.Format("2006-01-02"),
+		"time":  now.Format("15:04"),
+		"title": titleFromFilename(filename),
+		"user":  currentUserName(),
+	}
+}
+
+// titleFromFilename turns a note name into a title: "work/team-sync.md" becomes "Team sync"
+func titleFromFilename(filename string) string {
+	title := path.Base(filename)
+	title = strings.TrimSuffix(title, path.Ext(title))
+	title = strings.TrimSpace(strings.NewReplacer("-", " ", "_", " ").Replace(title))
+	if title == "" {
+		return ""
+	}
+	runes := []rune(title)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+// currentUserName returns the login name of the user running ks
+func currentUserName() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
+// templatePlaceholders returns the variables in a template that have no value yet, in order
+func templatePlaceholders(text string, vars map[string]string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+		name := strings.ToLower(match[1])
+		if _, ok := vars[name]; ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// expandTemplate fills in the variables that have a value, leaving the others as they are
+func expandTemplate(text string, vars map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+		name := strings.ToLower(placeholderPattern.FindStringSubmatch(match)[1])
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// varsFlag collects repeated --var name=value flags
+type varsFlag map[string]string
+
+func (v varsFlag) String() string {
+	return ""
+}
+
+func (v varsFlag) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("expected name=value")
+	}
+	v[strings.ToLower(strings.TrimSpace(name))] = value
+	return nil
+}
+
+// runNewCommand handles "ks new [--template name] <filename>"
+func runNewCommand(args []string) {
+	fs := flag.NewFlagSet("new", flag.ExitOnError)
+	var templateName string
+	var list bool
+	vars := make(varsFlag)
+	fs.StringVar(&templateName, "T", "", "Template to start from")
+	fs.StringVar(&templateName, "template", "", "Template to start from")
+	fs.Var(vars, "var", "Value for a template placeholder (name=value, repeatable)")
+	fs.BoolVar(&list, "l", false, "List the available templates")
+	fs.BoolVar(&list, "list", false, "List the available templates")
+	fs.BoolVar(&encryptWrites, "encrypt", false, "Encrypt the note with a passphrase")
+	fs.Usage = func() { printNewUsage(os.Stderr) }
+
+	// Flags may come before or after the filename
+	fs.Parse(args)
+	var filename string
+	if fs.NArg() > 0 {
+		filename = fs.Arg(0)
+		fs.Parse(fs.Args()[1:])
+	}
+	if fs.NArg() > 0 {
+		usageError(printNewUsage)
+	}
+	notesDir := cliNotesDir()
+
+	if list {
+		for _, name := range listTemplates(notesDir) {
+			if _, inFolder := templateFile(notesDir, name); !inFolder {
+				fmt.Println(name + theme.Muted.Render(" (built-in)"))
+				continue
+			}
+			fmt.Println(name)
+		}
+		return
+	}
+
+	// No filename: the interactive New Note flow, which has its own template picker
+	if filename == "" {
+		if !isTTY() {
+			usageError(printNewUsage)
+		}
+		runInteractiveCreate()
+		return
+	}
+
+	checkName(filename)
+	if _, err := os.Stat(notePath(notesDir, filename)); err == nil {
+		fail(exitExists, "Note '%s' already exists", filename)
+	}
+
+	content := ""
+	if templateName != "" {
+		text, err := readTemplate(notesDir, templateName)
+		if err != nil {
+			fail(exitNotFound, "%v (see 'ks new --list')", err)
+		}
+
+		values := templateVars(filename, time.Now())
+		for name, value := range vars {
+			values[name] = value
+		}
+		missing := templatePlaceholders(text, values)
+		if len(missing) > 0 && !isTTY() {
+			fail(exitUsage, "The template needs values for: %s (use --var name=value)", strings.Join(missing, ", "))
+		}
+		// Ask for the template's own placeholders
+		reader := bufio.NewReader(os.Stdin)
+		for _, name := range missing {
+			fmt.Print(theme.Primary.Render(name + ": "))
+			line, _ := reader.ReadString('\n')
+			values[name] = strings.TrimSpace(line)
+		}
+		content = expandTemplate(text, values)
+	}
+
+	// Finish the note in the editor, or write it as it is when not in a terminal
+	if !isTTY() {
+		writeNote(filename, content)
+		return
+	}
+	note, ok := interactiveContent(filename, content)
+	if !ok {
+		fail(exitCancelled, "Write cancelled")
+	}
+	writeNote(filename, note)
+}
+
+// printNewUsage shows the help for "ks new"
+func printNewUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ks new [--template name] [--var name=value...] [--encrypt] <filename>")
+	fmt.Fprintln(w, "   or: ks new --list")
+	fmt.Fprintln(w, "\nCreates a note, optionally from a template, and opens it in the editor.")
+	fmt.Fprintln(w, "Templates are the files in the templates folder (templates_dir, default")
+	fmt.Fprintln(w, "'templates' in the notes directory) plus the built-in meeting, postmortem")
+	fmt.Fprintln(w, "and adr templates.")
+	fmt.Fprintln(w, "\nVariables:")
+	fmt.Fprintln(w, "  {{date}}    today's date, as YYYY-MM-DD")
+	fmt.Fprintln(w, "  {{time}}    the current time, as HH:MM")
+	fmt.Fprintln(w, "  {{title}}   the note's name as a title (team-sync.md gives Team sync)")
+	fmt.Fprintln(w, "  {{user}}    your user name")
+	fmt.Fprintln(w, "  {{other}}   any other name is asked for, or given with --var other=value")
+	fmt.Fprintln(w, "\nExamples:")
+	fmt.Fprintln(w, "  ks new --template meeting standup.md")
+	fmt.Fprintln(w, "  ks new -T adr --var status=accepted adr/0007-queues.md")
+}
@@ -0,0 +1,517 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// trashEntry is a deleted note (or folder) waiting in the trash
+// Each entry lives in .ks/trash/<id>/ next to an info.json describing it
+type trashEntry struct {
+	ID      string    `json:"-"`
+	Path    string    `json:"path"` // original note name, e.g. "work/standup.md"
+	Deleted time.Time `json:"deleted"`
+	IsDir   bool      `json:"is_dir,omitempty"`
+	Size    int64     `json:"size"`
+}
+
+func (e trashEntry) FilterValue() string { return e.Path }
+
+func (e trashEntry) Title() string {
+	if e.IsDir {
+		return e.Path + "/"
+	}
+	return e.Path
+}
+
+func (e trashEntry) Description() string {
+	desc := "deleted " + e.Deleted.Format("2006-01-02 15:04")
+	if !e.IsDir {
+		desc += " • " + formatSize(e.Size)
+	}
+	return desc
+}
+
+// trashDir returns the folder holding the trash of a notes directory
+func trashDir(notesDir string) string {
+	return filepath.Join(notesDir, ".ks", "trash")
+}
+
+// payloadPath returns where the trashed file or folder itself is stored
+func (e trashEntry) payloadPath(notesDir string) string {
+	return filepath.Join(trashDir(notesDir), e.ID, path.Base(e.Path))
+}
+
+// historyPath returns where the trashed note's history is kept (a folder's
+// holds the histories of its notes); note names can't start with a dot
+func (e trashEntry) historyPath(notesDir string) string {
+	return filepath.Join(trashDir(notesDir), e.ID, ".history")
+}
+
+// moveToTrash moves a note or folder into the trash instead of deleting it,
+// taking its history along so emptying the trash leaves no copies behind
+// Empty folders are simply removed - there is nothing to restore
+func moveToTrash(notesDir, name string) error {
+	filePath := notePath(notesDir, name)
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		if entries, err := os.ReadDir(filePath); err == nil && len(entries) == 0 {
+			return os.Remove(filePath)
+		}
+	}
+
+	purgeTrash(notesDir)
+
+	entry := trashEntry{Path: name, Deleted: time.Now(), IsDir: info.IsDir(), Size: info.Size()}
+
+	// IDs are the deletion time, with a counter if several notes go in the same second
+	if err := os.MkdirAll(trashDir(notesDir), 0755); err != nil {
+		return err
+	}
+	base := entry.Deleted.Format("20060102-150405")
+	entry.ID = base
+	for i := 2; ; i++ {
+		err := os.Mkdir(filepath.Join(trashDir(notesDir), entry.ID), 0755)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return err
+		}
+		entry.ID = fmt.Sprintf("%s-%d", base, i)
+	}
+	entryDir := filepath.Join(trashDir(notesDir), entry.ID)
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(entryDir, "info.json"), data, 0644); err != nil {
+		os.RemoveAll(entryDir)
+		return err
+	}
+
+	if err := os.Rename(filePath, entry.payloadPath(notesDir)); err != nil {
+		os.RemoveAll(entryDir)
+		return err
+	}
+	if _, err := os.Stat(historyDir(notesDir, name)); err == nil {
+		os.Rename(historyDir(notesDir, name), entry.historyPath(notesDir))
+	}
+
+	return nil
+}
+
+// listTrash returns the entries in the trash, most recently deleted first
+func listTrash(notesDir string) ([]trashEntry, error) {
+	dirEntries, err := os.ReadDir(trashDir(notesDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []trashEntry
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(trashDir(notesDir), dirEntry.Name(), "info.json"))
+		if err != nil {
+			continue
+		}
+		var entry trashEntry
+		if err := json.Unmarshal(data, &entry); err != nil || entry.Path == "" {
+			continue
+		}
+		entry.ID = dirEntry.Name()
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Deleted.After(entries[j].Deleted)
+	})
+
+	return entries, nil
+}
+
+// findTrashEntry looks an entry up by ID, or by original name (most recent deletion wins)
+func findTrashEntry(entries []trashEntry, ref string) (trashEntry, bool) {
+	for _, entry := range entries {
+		if entry.ID == ref {
+			return entry, true
+		}
+	}
+	ref = strings.TrimSuffix(ref, "/")
+	for _, entry := range entries {
+		if entry.Path == ref {
+			return entry, true
+		}
+	}
+	return trashEntry{}, false
+}
+
+// restoreFromTrash moves an entry back into the notes directory as name
+// It refuses to overwrite an existing note
+func restoreFromTrash(notesDir string, entry trashEntry, name string) error {
+	if err := validateFilename(name); err != nil {
+		return err
+	}
+
+	target := notePath(notesDir, name)
+	if _, err := os.Lstat(target); err == nil {
+		return fmt.Errorf("'%s' already exists", name)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(entry.payloadPath(notesDir), target); err != nil {
+		return err
+	}
+	if _, err := os.Stat(entry.historyPath(notesDir)); err == nil {
+		dir := historyDir(notesDir, name)
+		if err := os.MkdirAll(filepath.Dir(dir), 0755); err == nil {
+			os.RemoveAll(dir)
+			os.Rename(entry.historyPath(notesDir), dir)
+		}
+	}
+
+	os.RemoveAll(filepath.Join(trashDir(notesDir), entry.ID))
+	updateIndex(notesDir, name)
+	gitCommit(notesDir, "Restore "+name+" from trash")
+	return nil
+}
+
+// removeTrashEntry deletes an entry from the trash for good, with its history
+func removeTrashEntry(notesDir string, entry trashEntry) error {
+	return os.RemoveAll(filepath.Join(trashDir(notesDir), entry.ID))
+}
+
+// emptyTrash permanently deletes every entry deleted before cutoff (zero cutoff = everything)
+// Returns the number of entries removed
+func emptyTrash(notesDir string, cutoff time.Time) (int, error) {
+	entries, err := listTrash(notesDir)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if !cutoff.IsZero() && entry.Deleted.After(cutoff) {
+			continue
+		}
+		if err := removeTrashEntry(notesDir, entry); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// purgeTrash drops entries older than the configured retention period
+// Errors are ignored: purging is housekeeping and will be retried on the next delete
+func purgeTrash(notesDir string) {
+	if config.TrashRetentionDays <= 0 {
+		return
+	}
+	emptyTrash(notesDir, time.Now().AddDate(0, 0, -config.TrashRetentionDays))
+}
+
+// runTrashCommand handles "ks trash [list|restore|empty]"
+func runTrashCommand(args []string) {
+	notesDir := cliNotesDir()
+	purgeTrash(notesDir)
+
+	subcommand := "list"
+	if len(args) > 0 {
+		subcommand = args[0]
+		args = args[1:]
+	}
+
+	switch subcommand {
+	case "list", "ls":
+		entries, err := listTrash(notesDir)
+		if err != nil {
+			failErr(err, "Could not read the trash")
+		}
+		if len(entries) == 0 {
+			fmt.Println("Trash is empty.")
+			return
+		}
+		for _, entry := range entries {
+			fmt.Printf("%s  %s  %s\n", theme.Muted.Render(entry.ID), entry.Title(), theme.Muted.Render(entry.Description()))
+		}
+
+	case "restore":
+		fs := flag.NewFlagSet("trash restore", flag.ExitOnError)
+		var as string
+		fs.StringVar(&as, "as", "", "Restore under a different name")
+		fs.Usage = func() { printTrashUsage(os.Stderr) }
+
+		// Allow the name before the flags: ks trash restore todo.md --as todo-old.md
+		var ref string
+		if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+			ref = args[0]
+			args = args[1:]
+		}
+		fs.Parse(args)
+		if ref == "" && fs.NArg() > 0 {
+			ref = fs.Arg(0)
+		}
+		if ref == "" {
+			usageError(printTrashUsage)
+		}
+
+		entries, err := listTrash(notesDir)
+		if err != nil {
+			failErr(err, "Could not read the trash")
+		}
+		entry, ok := findTrashEntry(entries, ref)
+		if !ok {
+			fail(exitNotFound, "'%s' is not in the trash", ref)
+		}
+
+		name := entry.Path
+		if as != "" {
+			name = as
+		}
+		checkName(name)
+		if _, err := os.Lstat(notePath(notesDir, name)); err == nil {
+			if as == "" {
+				fail(exitExists, "'%s' already exists - use --as <name> to restore it under another name", name)
+			}
+			fail(exitExists, "'%s' already exists", name)
+		}
+		if err := restoreFromTrash(notesDir, entry, name); err != nil {
+			failErr(err, "Could not restore the note")
+		}
+		fmt.Println(theme.Success.Render("✓ Restored " + name))
+
+	case "empty":
+		fs := flag.NewFlagSet("trash empty", flag.ExitOnError)
+		var force bool
+		fs.BoolVar(&force, "force", false, "Don't ask for confirmation")
+		fs.Usage = func() { printTrashUsage(os.Stderr) }
+		fs.Parse(args)
+
+		entries, err := listTrash(notesDir)
+		if err != nil {
+			failErr(err, "Could not read the trash")
+		}
+		if len(entries) == 0 {
+			fmt.Println("Trash is empty.")
+			return
+		}
+		if !force && !confirm(fmt.Sprintf("Permanently delete %d items?", len(entries))) {
+			fail(exitCancelled, "Cancelled")
+		}
+
+		removed, err := emptyTrash(notesDir, time.Time{})
+		if err != nil {
+			fail(exitIO, "Could not empty the trash: %v", err)
+		}
+		fmt.Println(theme.Success.Render(fmt.Sprintf("✓ Permanently deleted %d items", removed)))
+
+	case "-h", "--help", "help":
+		printTrashUsage(os.Stdout)
+
+	default:
+		usageError(printTrashUsage)
+	}
+}
+
+// printTrashUsage displays the trash help message
+func printTrashUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ks trash [list|restore|empty]")
+	fmt.Fprintln(w, "\nCommands:")
+	fmt.Fprintln(w, "  list                        Show deleted notes (default)")
+	fmt.Fprintln(w, "  restore <name|id> [--as n]  Put a note back where it was (or under a new name)")
+	fmt.Fprintln(w, "  empty [--force]             Permanently delete everything in the trash")
+	fmt.Fprintf(w, "\nDeleted notes are kept for %d days (trash_retention_days in the config file, 0 = forever).\n", config.TrashRetentionDays)
+}
+
+// trashListModel is the Trash view of the main menu
+type trashListModel struct {
+	list         list.Model
+	notesDir     string
+	confirming   string // "", "delete" or "empty"
+	notification string
+	isError      bool
+	width        int
+	height       int
+	quitting     bool
+}
+
+func newTrashListModel(notesDir string) trashListModel {
+	l := list.New(nil, newListDelegate(), 0, 0)
+	l.Title = "Trash"
+	l.Styles.Title = theme.Header
+	l.SetShowStatusBar(true)
+	l.SetFilteringEnabled(true)
+	l.FilterInput.Prompt = "Search: "
+	l.SetStatusBarItemName("deleted note", "deleted notes")
+	l.AdditionalShortHelpKeys = trashHelpKeys
+	l.AdditionalFullHelpKeys = trashHelpKeys
+
+	m := trashListModel{list: l, notesDir: notesDir}
+	m.reload()
+	return m
+}
+
+// trashHelpKeys lists the Trash view's own keys in the help line
+func trashHelpKeys() []key.Binding {
+	return []key.Binding{
+		key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "restore")),
+		key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "delete forever")),
+		key.NewBinding(key.WithKeys("E"), key.WithHelp("E", "empty trash")),
+	}
+}
+
+// reload reads the trash again after a change
+func (m *trashListModel) reload() {
+	entries, err := listTrash(m.notesDir)
+	if err != nil {
+		m.notify("Error reading trash: "+err.Error(), true)
+	}
+	items := make([]list.Item, len(entries))
+	for i, entry := range entries {
+		items[i] = entry
+	}
+	m.list.SetItems(items)
+}
+
+// notify shows a message above the list
+func (m *trashListModel) notify(message string, isError bool) {
+	m.notification = message
+	m.isError = isError
+}
+
+func (m trashListModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m trashListModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case clearNotificationMsg:
+		m.notification = ""
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.confirming != "" {
+			if msg.String() == "y" || msg.String() == "Y" {
+				switch m.confirming {
+				case "delete":
+					if entry, ok := m.list.SelectedItem().(trashEntry); ok {
+						if err := removeTrashEntry(m.notesDir, entry); err != nil {
+							m.notify("Could not delete '"+entry.Path+"': "+err.Error(), true)
+						} else {
+							m.notify("Permanently deleted '"+entry.Path+"'", false)
+						}
+					}
+				case "empty":
+					removed, err := emptyTrash(m.notesDir, time.Time{})
+					if err != nil {
+						m.notify("Error emptying trash: "+err.Error(), true)
+					} else {
+						m.notify(fmt.Sprintf("Permanently deleted %d items", removed), false)
+					}
+				}
+				m.reload()
+				m.confirming = ""
+				return m, clearNotificationAfter(3 * time.Second)
+			}
+			m.confirming = ""
+			return m, nil
+		}
+
+		if m.list.FilterState() == list.Filtering {
+			var cmd tea.Cmd
+			m.list, cmd = m.list.Update(msg)
+			return m, cmd
+		}
+
+		switch msg.String() {
+		case "esc", "q", "ctrl+c":
+			if msg.String() == "esc" && m.list.FilterState() == list.FilterApplied {
+				break
+			}
+			m.quitting = true
+			return m, screenDone
+
+		case "r", "enter":
+			if entry, ok := m.list.SelectedItem().(trashEntry); ok {
+				if err := restoreFromTrash(m.notesDir, entry, entry.Path); err != nil {
+					m.notify("Could not restore: "+err.Error(), true)
+				} else {
+					m.notify("Restored '"+entry.Path+"'", false)
+				}
+				m.reload()
+				return m, clearNotificationAfter(3 * time.Second)
+			}
+
+		case "d":
+			if _, ok := m.list.SelectedItem().(trashEntry); ok {
+				m.confirming = "delete"
+				return m, nil
+			}
+
+		case "E":
+			if len(m.list.Items()) > 0 {
+				m.confirming = "empty"
+				return m, nil
+			}
+		}
+
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		h, v := lipgloss.NewStyle().GetFrameSize()
+		m.list.SetSize(msg.Width-h, msg.Height-v-1) // -1 for the notification line
+	}
+
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+func (m trashListModel) View() string {
+	if m.quitting {
+		return ""
+	}
+
+	status := ""
+	switch {
+	case m.confirming == "delete":
+		if entry, ok := m.list.SelectedItem().(trashEntry); ok {
+			status = theme.Warning.Render(fmt.Sprintf("Permanently delete '%s'? (y/n)", entry.Path))
+		}
+	case m.confirming == "empty":
+		status = theme.Warning.Render(fmt.Sprintf("Permanently delete all %d items? (y/n)", len(m.list.Items())))
+	case m.notification != "" && m.isError:
+		status = theme.Error.Render("✗ " + m.notification)
+	case m.notification != "":
+		status = theme.Success.Render("✓ " + m.notification)
+	case len(m.list.Items()) == 0:
+		status = theme.Muted.Render("Trash is empty")
+	}
+
+	return status + "\n" + m.list.View()
+}
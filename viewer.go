@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// noteViewerModel is the read-only viewer opened by "ks -r" and "v" in the list
+// Markdown notes are rendered with the current theme; r switches to the raw text.
+// Tab picks one of the note's [[links]] and Enter follows it.
+type noteViewerModel struct {
+	notesDir  string
+	filename  string
+	content   string
+	viewport  viewport.Model
+	ready     bool
+	showHelp  bool
+	edit      bool   // the user pressed e to edit the note
+	status    string // message shown in the header, e.g. after saving from the editor
+	statusErr bool
+	links     []string     // [[link]] targets in the note
+	link      int          // selected link (-1 = none)
+	back      []viewerPage // notes left by following links, for Backspace
+	width     int
+	height    int
+}
+
+// viewerPage is a note the viewer showed before following a link
+type viewerPage struct {
+	filename string
+	content  string
+	yOffset  int
+}
+
+func newNoteViewerModel(notesDir, filename, content string) noteViewerModel {
+	m := noteViewerModel{notesDir: notesDir}
+	m.load(filename, content)
+	return m
+}
+
+// load shows another note from the top
+func (m *noteViewerModel) load(filename, content string) {
+	m.filename = filename
+	m.content = content
+	m.links = linkTargets(content)
+	m.link = -1
+	if m.ready {
+		m.setContent()
+		m.viewport.GotoTop()
+	}
+}
+
+// follow opens the note the selected link points to
+func (m *noteViewerModel) follow() {
+	if m.link < 0 || m.link >= len(m.links) {
+		return
+	}
+	target := m.links[m.link]
+	name, ok := findNote(m.notesDir, target)
+	if !ok {
+		m.setStatus(fmt.Sprintf("No note named '%s'", target), true)
+		return
+	}
+	content, _, err := readNoteText(m.notesDir, name)
+	if err == errLocked {
+		m.setStatus(fmt.Sprintf("'%s' is encrypted - open it from the list to unlock it", name), true)
+		return
+	}
+	if err != nil {
+		m.setStatus("Could not read the note: "+err.Error(), true)
+		return
+	}
+
+	m.back = append(m.back, viewerPage{m.filename, m.content, m.viewport.YOffset})
+	m.load(name, content)
+}
+
+// goBack returns to the note the last link was followed from
+func (m *noteViewerModel) goBack() {
+	if len(m.back) == 0 {
+		return
+	}
+	page := m.back[len(m.back)-1]
+	m.back = m.back[:len(m.back)-1]
+	m.load(page.filename, page.content)
+	m.viewport.SetYOffset(page.yOffset)
+}
+
+// setStatus shows a message in the header until the next key press
+func (m *noteViewerModel) setStatus(status string, isError bool) {
+	m.status = status
+	m.statusErr = isError
+}
+
+// setContent formats the note for the viewport's width, keeping the scroll position
+func (m *noteViewerModel) setContent() {
+	yOffset := m.viewport.YOffset
+	m.viewport.SetContent(formatNote(m.filename, m.content, m.viewport.Width))
+	m.viewport.SetYOffset(yOffset)
+}
+
+// reload reads the note again after it was edited
+func (m *noteViewerModel) reload() {
+	content, _, err := readNoteText(m.notesDir, m.filename)
+	if err != nil {
+		m.setStatus("Could not read the note: "+err.Error(), true)
+		return
+	}
+	m.content = content
+	m.links = linkTargets(m.content)
+	m.link = min(m.link, len(m.links)-1)
+	if m.ready {
+		m.setContent()
+	}
+}
+
+// resize fits the viewport between the header and the footer
+func (m *noteViewerModel) resize() {
+	footerHeight := 1
+	if m.showHelp {
+		footerHeight = len(viewerHelp) + 2
+	}
+	height := max(m.height-2-footerHeight, 1)
+
+	if !m.ready {
+		m.viewport = viewport.New(m.width, height)
+		m.ready = true
+	} else {
+		m.viewport.Width = m.width
+		m.viewport.Height = height
+	}
+	m.setContent()
+}
+
+func (m noteViewerModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m noteViewerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.resize()
+		return m, nil
+
+	case tea.KeyMsg:
+		m.setStatus("", false)
+		switch msg.String() {
+		case "q", "esc", "ctrl+c":
+			return m, screenDone
+
+		case "e":
+			m.edit = true
+			return m, screenDone
+
+		case "r":
+			// Switch between rendered Markdown and raw text (and remember it for next time)
+			config.Markdown = !config.Markdown
+			m.setContent()
+			if err := saveConfigValue("markdown", strconv.FormatBool(config.Markdown)); err != nil {
+				m.setStatus("Could not save the setting: "+err.Error(), true)
+			}
+			return m, nil
+
+		case "tab", "shift+tab":
+			// Pick the next (or previous) link
+			if len(m.links) > 0 {
+				step := 1
+				if msg.String() == "shift+tab" {
+					step = len(m.links) - 1
+				}
+				if m.link < 0 && step > 1 {
+					m.link = 0
+				}
+				m.link = (m.link + step) % len(m.links)
+			}
+			return m, nil
+
+		case "enter":
+			m.follow()
+			return m, nil
+
+		case "backspace":
+			m.goBack()
+			return m, nil
+
+		case "?":
+			m.showHelp = !m.showHelp
+			m.resize()
+			return m, nil
+
+		case "g", "home":
+			m.viewport.GotoTop()
+			return m, nil
+
+		case "G", "end":
+			m.viewport.GotoBottom()
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.viewport, cmd = m.viewport.Update(msg)
+	return m, cmd
+}
+
+// viewerHelp lists the viewer's keys for the full help
+var viewerHelp = []string{
+	"↑/k ↓/j      scroll one line",
+	"PgUp/b PgDn/f/space   scroll one page",
+	"u/d          scroll half a page",
+	"g/G          top / bottom",
+	"r            rendered / raw text",
+	"Tab/S-Tab    pick a [[link]]",
+	"Enter        follow the picked link",
+	"Backspace    back to the previous note",
+	"e            edit the note",
+	"q/Esc        close",
+}
+
+func (m noteViewerModel) View() string {
+	if !m.ready {
+		return ""
+	}
+
+	mode := "rendered"
+	if !config.Markdown || !isMarkdown(m.filename) {
+		mode = "raw"
+	}
+	header := theme.Header.Render(m.filename) + " " +
+		theme.Muted.Render(fmt.Sprintf("%s • %3.f%%", mode, m.viewport.ScrollPercent()*100))
+	switch {
+	case m.status != "" && m.statusErr:
+		header += "  " + theme.Error.Render("✗ "+m.status)
+	case m.status != "":
+		header += "  " + theme.Success.Render("✓ "+m.status)
+	case m.link >= 0:
+		header += "  " + theme.Accent.Render("→ [["+m.links[m.link]+"]]") +
+			theme.Muted.Render(fmt.Sprintf(" (%d/%d) Enter: follow", m.link+1, len(m.links)))
+	}
+
+	footer := theme.Muted.Render("↑/↓: scroll • r: raw/rendered • e: edit • ?: help • q: quit")
+	if len(m.links) > 0 || len(m.back) > 0 {
+		footer = theme.Muted.Render("↑/↓: scroll • Tab: links • Backspace: back • r: raw/rendered • e: edit • ?: help • q: quit")
+	}
+	if m.showHelp {
+		footer = theme.Muted.Render(strings.Join(viewerHelp, "\n") + "\n\n? to hide help")
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left, header, "", m.viewport.View(), footer)
+}
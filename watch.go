@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// watchSettleTime is how long a burst of changes (a save is several events) must
+// be quiet before the list reloads
+const watchSettleTime = 150 * time.Millisecond
+
+// pollInterval is how often the notes directory is rescanned where it can't be watched
+const pollInterval = 2 * time.Second
+
+// notesChangedMsg tells the list that notes were added, changed or removed on disk
+type notesChangedMsg struct{}
+
+// notesWatcher reports changes made to the notes directory while the TUI is open,
+// whether by ks itself, scripts or another terminal
+type notesWatcher struct {
+	changes chan struct{}
+	stop    func()
+	once    sync.Once
+}
+
+// watchNotes starts watching the notes directory and its folders
+// Uses inotify where available and falls back to polling
+func watchNotes(notesDir string) *notesWatcher {
+	w := &notesWatcher{changes: make(chan struct{}, 1)}
+	stop, err := startWatching(notesDir, w.changed)
+	if err != nil {
+		stop = pollNotes(notesDir, w.changed)
+	}
+	w.stop = stop
+	return w
+}
+
+// changed records that something changed; repeated changes before the list
+// catches up are merged into one
+func (w *notesWatcher) changed() {
+	select {
+	case w.changes <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops watching. A pending waitForChange returns nil.
+func (w *notesWatcher) Close() {
+	if w == nil {
+		return
+	}
+	w.once.Do(func() {
+		w.stop()
+		close(w.changes)
+	})
+}
+
+// waitForChange returns a command that delivers a notesChangedMsg once the notes
+// directory has changed and settled
+func waitForChange(w *notesWatcher) tea.Cmd {
+	if w == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		if _, ok := <-w.changes; !ok {
+			return nil
+		}
+		for {
+			select {
+			case _, ok := <-w.changes:
+				if !ok {
+					return nil
+				}
+			case <-time.After(watchSettleTime):
+				return notesChangedMsg{}
+			}
+		}
+	}
+}
+
+// pollNotes rescans the notes directory every pollInterval and calls changed when
+// anything in it differs from the last scan. Returns a function that stops polling.
+func pollNotes(notesDir string, changed func()) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	last := scanNotes(notesDir)
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if current := scanNotes(notesDir); current != last {
+					last = current
+					changed()
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// scanNotes fingerprints the names, sizes and modification times of everything
+// in the notes directory, skipping hidden files and folders
+func scanNotes(notesDir string) uint64 {
+	h := fnv.New64a()
+	filepath.WalkDir(notesDir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if filePath != notesDir && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", filePath, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return h.Sum64()
+}
@@ -0,0 +1,125 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// inotifyMask is the set of events that can change what the list shows
+const inotifyMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY | unix.IN_CLOSE_WRITE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_DELETE_SELF | unix.IN_MOVE_SELF
+
+// startWatching watches the notes directory and every (non-hidden) folder in it
+// with inotify, adding folders as they are created
+func startWatching(notesDir string, changed func()) (func(), error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	// A non-blocking descriptor goes through Go's poller, so Close wakes up Read
+	file := os.NewFile(uintptr(fd), "inotify")
+
+	w := &inotifyWatcher{fd: fd, dirs: make(map[int]string)}
+	if err := w.addTree(notesDir); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		w.run(file, changed)
+	}()
+
+	return func() {
+		file.Close()
+		<-stopped
+	}, nil
+}
+
+// inotifyWatcher maps inotify watch descriptors to the folders they watch
+type inotifyWatcher struct {
+	fd   int
+	dirs map[int]string
+}
+
+// addTree watches dir and the folders below it, skipping hidden ones (.ks, .git)
+func (w *inotifyWatcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if filePath == dir {
+				return err
+			}
+			return nil
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if filePath != dir && strings.HasPrefix(entry.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		wd, err := unix.InotifyAddWatch(w.fd, filePath, inotifyMask|unix.IN_ONLYDIR)
+		if err != nil {
+			if filePath == dir {
+				return err
+			}
+			return nil
+		}
+		w.dirs[wd] = filePath
+		return nil
+	})
+}
+
+// run reads events until the inotify file is closed
+func (w *inotifyWatcher) run(file *os.File, changed func()) {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		notify := false
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			name := string(bytes.TrimRight(buf[nameStart:nameStart+int(event.Len)], "\x00"))
+			offset = nameStart + int(event.Len)
+
+			if event.Mask&unix.IN_Q_OVERFLOW != 0 {
+				// Events were lost - reload to be safe
+				notify = true
+				continue
+			}
+			if event.Mask&unix.IN_IGNORED != 0 {
+				delete(w.dirs, int(event.Wd))
+				continue
+			}
+			// Temporary files from saves, the .ks state folder and .git don't show in the list
+			if strings.HasPrefix(name, ".") {
+				continue
+			}
+
+			notify = true
+			if event.Mask&unix.IN_ISDIR != 0 && event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+				parent, ok := w.dirs[int(event.Wd)]
+				if ok {
+					w.addTree(filepath.Join(parent, name))
+				}
+			}
+		}
+
+		if notify {
+			changed()
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// startWatching is only implemented with inotify on Linux; elsewhere the notes
+// directory is polled instead
+func startWatching(notesDir string, changed func()) (func(), error) {
+	return nil, errors.New("directory watching not supported on this platform")
+}